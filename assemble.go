@@ -0,0 +1,57 @@
+package jina
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// compileProgram assembles TEAL with algod, AssembleOffline is only built with the offline tag
+func compileProgram(algodClient *algod.Client, tealFile []byte) (program []byte, err error) {
+	if algodClient == nil {
+		return nil, errors.New("compiling TEAL needs an algod client")
+	}
+	return compileAlgod(algodClient, tealFile)
+}
+
+func compileAlgod(algodClient *algod.Client, tealFile []byte) (program []byte, err error) {
+	response, err := algodClient.TealCompile(tealFile).Do(context.Background())
+	if err != nil {
+		return
+	}
+	program, err = base64.StdEncoding.DecodeString(response.Result)
+	if err == nil && response.Hash != crypto.AddressFromProgram(program).String() {
+		err = fmt.Errorf("algod program hash %s does not match its result", response.Hash)
+	}
+	return
+}
+
+// AssemblyCheck compares the offline assembly of a TEAL file with algod's
+type AssemblyCheck struct {
+	Offline, Algod         []byte
+	OfflineHash, AlgodHash types.Address // program addresses
+}
+
+// Match reports whether both assemblers produced the same bytecode
+func (c AssemblyCheck) Match() bool {
+	return bytes.Equal(c.Offline, c.Algod)
+}
+
+// CheckAssembly assembles a TEAL file offline and with algod
+func CheckAssembly(algodClient *algod.Client, tealFile []byte) (c AssemblyCheck, err error) {
+	if c.Offline, err = AssembleOffline(tealFile); err != nil {
+		return c, fmt.Errorf("offline: %v", err)
+	}
+	if c.Algod, err = compileAlgod(algodClient, tealFile); err != nil {
+		return c, fmt.Errorf("algod: %v", err)
+	}
+	c.OfflineHash = crypto.AddressFromProgram(c.Offline)
+	c.AlgodHash = crypto.AddressFromProgram(c.Algod)
+	return
+}
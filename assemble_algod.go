@@ -0,0 +1,14 @@
+//go:build !offline
+// +build !offline
+
+package jina
+
+import "errors"
+
+// ErrNoOfflineAssembler is returned by AssembleOffline in builds without the offline tag
+var ErrNoOfflineAssembler = errors.New("offline assembly needs the offline build tag and go-algorand with libsodium")
+
+// AssembleOffline is only available in builds with the offline tag
+func AssembleOffline(tealFile []byte) ([]byte, error) {
+	return nil, ErrNoOfflineAssembler
+}
@@ -0,0 +1,31 @@
+//go:build offline
+// +build offline
+
+// The offline tag compiles go-algorand, linking through cgo the libsodium fork built from its sources
+// (make crypto/libs/<os>/<arch>/lib/libsodium.a, with the dependencies of scripts/configure_dev.sh such as boost).
+// The module cache copy cannot build it, replace go-algorand with a clone where it was built, see the README
+
+package jina
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/algorand/go-algorand/data/transactions/logic"
+)
+
+// AssembleOffline assembles TEAL with the go-algorand assembler algod uses, without a node
+func AssembleOffline(tealFile []byte) ([]byte, error) {
+	ops, err := logic.AssembleString(string(tealFile))
+	if err != nil {
+		if ops != nil && len(ops.Errors) > 0 {
+			msgs := make([]string, len(ops.Errors))
+			for i, e := range ops.Errors {
+				msgs[i] = e.Error()
+			}
+			return nil, fmt.Errorf("%s", strings.Join(msgs, "; "))
+		}
+		return nil, err
+	}
+	return ops.Program, nil
+}
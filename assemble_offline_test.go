@@ -0,0 +1,75 @@
+//go:build offline
+// +build offline
+
+package jina
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestAssembleOffline(t *testing.T) {
+	params := map[string]TemplateParams{
+		"logicSigDelegated.teal": testTerms.Template(testLender().Address).Params(),
+		"dispense.teal":          DispenserTemplate{Asset: 3, Cap: 4}.Params(),
+	}
+	files, err := filepath.Glob("./teal/*.teal")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no teal files, %v", err)
+	}
+	for _, f := range files {
+		tealFile, err := ReadTemplate(f, params[filepath.Base(f)])
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		program, err := AssembleOffline(tealFile)
+		if err != nil {
+			t.Errorf("%s: %v", f, err)
+			continue
+		}
+		if len(program) == 0 {
+			t.Errorf("%s assembled to nothing", f)
+		}
+	}
+	if _, err = AssembleOffline([]byte("#pragma version 6\nint 1\nbogus")); err == nil {
+		t.Errorf("assembled an unknown opcode")
+	}
+}
+
+// lenderProgramOffline assembles the lender lsig template for the terms of lender, as ExpectedLenderProgram with algod
+func lenderProgramOffline(t *testing.T, terms LenderTerms, lender types.Address) []byte {
+	tealFile, err := ReadTemplate("./teal/logicSigDelegated.teal", terms.Template(lender).Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := AssembleOffline(tealFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestLenderProgramsOffline(t *testing.T) {
+	for amount := range lenderPrograms {
+		terms := testTerms
+		terms.Amount = amount
+		program := lenderProgramOffline(t, terms, testLender().Address)
+		if !bytes.Equal(program, lenderProgram(t, amount)) {
+			t.Errorf("lender program lending %d is stale, now %x", amount, program)
+		}
+	}
+}
+
+func TestSignLenderLsigOffline(t *testing.T) {
+	l, err := SignLenderLsig(lenderProgramOffline(t, testTerms, testLender().Address), testTerms, AccountSigner{testLender()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := InspectLenderLsig(l.Lsig, l.Lender, lenderProgram(t, testTerms.Amount), 10, 7, 500); !r.Safe() {
+		t.Errorf("offline lsig unsafe: %v", r.Warnings)
+	}
+}
@@ -0,0 +1,161 @@
+package jina
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/algorand/go-algorand-sdk/abi"
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// opup calls pooling budget for ed25519verify, made by jina in borrow and added after the call to liquidate
+const attestationOpups = 3
+
+// PriceAttestation is an off-chain collateral price.
+// It is encoded as asset||price||round||expiry, each a big-endian uint64.
+type PriceAttestation struct {
+	AssetID uint64
+	Price   uint64
+	Round   uint64 // round the price was observed
+	Expiry  uint64 // last round the attestation can be used
+}
+
+// Encode returns the canonical 32 byte message that is signed
+func (a PriceAttestation) Encode() (msg [32]byte) {
+	binary.BigEndian.PutUint64(msg[0:8], a.AssetID)
+	binary.BigEndian.PutUint64(msg[8:16], a.Price)
+	binary.BigEndian.PutUint64(msg[16:24], a.Round)
+	binary.BigEndian.PutUint64(msg[24:32], a.Expiry)
+	return
+}
+
+// DecodePriceAttestation parses a canonical attestation message
+func DecodePriceAttestation(msg []byte) (a PriceAttestation, err error) {
+	if len(msg) != 32 {
+		return a, fmt.Errorf("attestation is %d bytes, want 32", len(msg))
+	}
+	a.AssetID = binary.BigEndian.Uint64(msg[0:8])
+	a.Price = binary.BigEndian.Uint64(msg[8:16])
+	a.Round = binary.BigEndian.Uint64(msg[16:24])
+	a.Expiry = binary.BigEndian.Uint64(msg[24:32])
+	return
+}
+
+// SignedPrice is an attestation with the signature the contract checks with ed25519verify.
+// The zero value makes the contracts fall back to the on-chain price.
+type SignedPrice struct {
+	PriceAttestation
+	Signature types.Signature
+	Signer    types.Address
+}
+
+// SignPrice signs an attestation for the approval program that will verify it.
+// ed25519verify binds the signature to the program, so jina and liquidator need their own.
+func SignPrice(sk ed25519.PrivateKey, a PriceAttestation, program []byte) (sp SignedPrice, err error) {
+	msg := a.Encode()
+	sig, err := crypto.TealSignFromProgram(sk, msg[:], program)
+	if err != nil {
+		return
+	}
+	sp.PriceAttestation = a
+	sp.Signature = sig
+	copy(sp.Signer[:], sk.Public().(ed25519.PublicKey))
+	return
+}
+
+// Verify checks the signature as ed25519verify would when run by program
+func (sp SignedPrice) Verify(program []byte) bool {
+	msg := sp.Encode()
+	return crypto.TealVerify(ed25519.PublicKey(sp.Signer[:]), msg[:], crypto.AddressFromProgram(program), sp.Signature)
+}
+
+// Usable reports whether the attestation is for asset and neither expired nor older than maxAge rounds at round,
+// maxAge being the manager's RiskParams.MaxAge
+func (sp SignedPrice) Usable(assetID, round, maxAge uint64) error {
+	if sp.AssetID != assetID {
+		return fmt.Errorf("attestation is for asset %d, not %d", sp.AssetID, assetID)
+	}
+	if sp.Expiry < round {
+		return fmt.Errorf("attestation expired at round %d", sp.Expiry)
+	}
+	if sp.Round > round || round-sp.Round > maxAge {
+		return fmt.Errorf("attestation of round %d is not within %d rounds before %d", sp.Round, maxAge, round)
+	}
+	return nil
+}
+
+func (sp SignedPrice) attested() bool {
+	return sp.Signer != types.Address{}
+}
+
+// method args of (byte[32],byte[64],address)
+func (sp SignedPrice) args() []interface{} {
+	return []interface{}{sp.Encode(), [64]byte(sp.Signature), sp.Signer}
+}
+
+// SignPriceForApp signs an attestation for the approval program currently deployed at appID
+func SignPriceForApp(algodClient *algod.Client, sk ed25519.PrivateKey, appID uint64, a PriceAttestation) (sp SignedPrice, err error) {
+	program, err := AppProgram(algodClient, appID)
+	if err != nil {
+		return
+	}
+	return SignPrice(sk, a, program)
+}
+
+// AppProgram fetches the approval program of an app
+func AppProgram(algodClient *algod.Client, appID uint64) ([]byte, error) {
+	app, err := algodClient.GetApplicationByID(appID).Do(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return app.Params.ApprovalProgram, nil
+}
+
+// PriceSigners lists the attestation keys registered in the manager app
+func PriceSigners(algodClient *algod.Client, mng uint64) (signers []types.Address, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	for k, v := range state {
+		if len(k) == 34 && strings.HasPrefix(k, "pk") && v.Uint != 0 {
+			var addr types.Address
+			copy(addr[:], k[2:])
+			signers = append(signers, addr)
+		}
+	}
+	return
+}
+
+// Register a price attestation key in the manager app
+func AddPriceSigner(algodClient *algod.Client, acct crypto.Account, pk types.Address, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "add_signer", []interface{}{pk}, contract_json)
+}
+
+// Revoke a price attestation key in the manager app
+func RemovePriceSigner(algodClient *algod.Client, acct crypto.Account, pk types.Address, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "remove_signer", []interface{}{pk}, contract_json)
+}
+
+// add manager opup calls after the attested call to pool budget for ed25519verify
+func addOpups(atc *future.AtomicTransactionComposer, mcp future.AddMethodCallParams, mng uint64) (err error) {
+	opup, err := abi.MethodFromSignature("opup()void")
+	if err != nil {
+		return
+	}
+	mcp.AppID = mng
+	mcp.SuggestedParams.Fee = 0
+	for i := 0; i < attestationOpups; i++ {
+		mcp.Note = []byte{byte(i)}
+		if err = atc.AddMethodCall(combine(mcp, opup, nil)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package jina
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestPriceAttestationEncoding(t *testing.T) {
+	a := PriceAttestation{AssetID: 2, Price: 50000000, Round: 1000, Expiry: 1100}
+	msg := a.Encode()
+	b, err := DecodePriceAttestation(msg[:])
+	if err != nil {
+		t.Fatalf("expecting no errors, got %s", err)
+	}
+	if a != b {
+		t.Errorf("round trip changed attestation: %+v != %+v", a, b)
+	}
+	if _, err = DecodePriceAttestation(msg[:31]); err == nil {
+		t.Errorf("expected error for short attestation")
+	}
+}
+
+func TestSignPrice(t *testing.T) {
+	_, sk, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := []byte{0x06, 0x81, 0x01}
+	a := PriceAttestation{AssetID: 2, Price: 50000000, Round: 1000, Expiry: 1100}
+	sp, err := SignPrice(sk, a, program)
+	if err != nil {
+		t.Fatalf("expecting no errors, got %s", err)
+	}
+	if !sp.Verify(program) {
+		t.Errorf("signature does not verify")
+	}
+	if sp.Verify([]byte{0x06, 0x81, 0x00}) {
+		t.Errorf("signature verifies for another program")
+	}
+	sp.Price++
+	if sp.Verify(program) {
+		t.Errorf("signature verifies for tampered price")
+	}
+	if err = sp.Usable(2, 1050, 100); err != nil {
+		t.Errorf("fresh attestation unusable: %v", err)
+	}
+	if err = sp.Usable(2, 1101, 200); err == nil {
+		t.Errorf("expected expired attestation")
+	}
+	if err = sp.Usable(2, 1050, 20); err == nil {
+		t.Errorf("expected attestation too old")
+	}
+	if err = sp.Usable(2, 999, 100); err == nil {
+		t.Errorf("expected attestation ahead of the round")
+	}
+	if err = sp.Usable(3, 1000, 100); err == nil {
+		t.Errorf("expected wrong asset")
+	}
+	if !sp.attested() || (SignedPrice{}).attested() {
+		t.Errorf("wrong attested flag")
+	}
+}
@@ -0,0 +1,158 @@
+package jina
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// AuctionRounds is the number of rounds an auction price takes to decay to its floor
+const AuctionRounds = 1000
+
+// Auction is a dutch auction of a loan, recorded in liquidator global state
+type Auction struct {
+	Borrower   types.Address
+	AssetID    uint64
+	StartRound uint64
+	StartPrice uint64 // collateral value, at least 105% of lamt
+	Floor      uint64 // lamt
+	LoanStart  uint64 // lrnd of the loan, a repay or reduce restarting it voids the auction
+}
+
+// Price is the minimum bid at round, as computed by the liquidator
+func (a Auction) Price(round uint64) uint64 {
+	elapsed := round - a.StartRound
+	if round < a.StartRound {
+		elapsed = 0
+	}
+	if elapsed >= AuctionRounds {
+		return a.Floor
+	}
+	return a.StartPrice - (a.StartPrice-a.Floor)*elapsed/AuctionRounds
+}
+
+// auction key: "a"||borrower||xaid
+func auctionKey(borrower types.Address, assetID uint64) string {
+	key := make([]byte, 41)
+	key[0] = 'a'
+	copy(key[1:33], borrower[:])
+	binary.BigEndian.PutUint64(key[33:], assetID)
+	return string(key)
+}
+
+func decodeAuction(key string, value []byte) (a Auction, ok bool) {
+	if len(key) != 41 || key[0] != 'a' || len(value) != 32 {
+		return
+	}
+	copy(a.Borrower[:], key[1:33])
+	a.AssetID = binary.BigEndian.Uint64([]byte(key[33:]))
+	a.StartRound = binary.BigEndian.Uint64(value[0:8])
+	a.StartPrice = binary.BigEndian.Uint64(value[8:16])
+	a.Floor = binary.BigEndian.Uint64(value[16:24])
+	a.LoanStart = binary.BigEndian.Uint64(value[24:32])
+	return a, true
+}
+
+// Auctions lists the auctions recorded by the liquidator app, including those voided by a repay or reduce
+func Auctions(algodClient *algod.Client, lqt uint64) (auctions []Auction, err error) {
+	state, err := globalState(algodClient, lqt)
+	if err != nil {
+		return
+	}
+	for k, v := range state {
+		if a, ok := decodeAuction(k, stateBytes(v)); ok {
+			auctions = append(auctions, a)
+		}
+	}
+	sort.Slice(auctions, func(i, j int) bool { return auctions[i].StartRound < auctions[j].StartRound })
+	return
+}
+
+// Make liquidator application call to start a dutch auction of an unhealthy loan
+func StartAuction(algodClient *algod.Client, acct crypto.Account, liquidatee types.Address, mng, jina, lqt, xaid uint64, contract_json string) (err error) {
+	return callAuction(algodClient, acct, "start_auction", liquidatee, mng, jina, lqt, xaid, contract_json)
+}
+
+// Make liquidator application call to close the auction of a repaid or healthy loan
+func SettleAuction(algodClient *algod.Client, acct crypto.Account, liquidatee types.Address, mng, jina, lqt, xaid uint64, contract_json string) (err error) {
+	return callAuction(algodClient, acct, "settle_auction", liquidatee, mng, jina, lqt, xaid, contract_json)
+}
+
+func callAuction(algodClient *algod.Client, acct crypto.Account, method string, liquidatee types.Address, mng, jina, lqt, xaid uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           lqt,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, method), []interface{}{liquidatee, xaid, mng, jina}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	_, err = atc.Execute(algodClient, context.Background(), 2)
+	return
+}
+
+// Make liquidator application call to bid amt of usdc or jusd on an auctioned loan
+func Bid(algodClient *algod.Client, acct crypto.Account, liquidatee, receiver types.Address, mng, jina, lqt, xaid, pay, amt uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	// payment, forward payment and clawback
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(4 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           lqt,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	txParams.Fee = 0
+	txn, _ := future.MakeAssetTransferTxn(acct.Address.String(), crypto.GetApplicationAddress(lqt).String(), amt, nil, txParams, "", pay)
+	stxn := future.TransactionWithSigner{Txn: txn, Signer: signer}
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "bid"), []interface{}{stxn, liquidatee, receiver, xaid, pay, mng, jina}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+	atc, err = withAccounts(atc, 1, crypto.GetApplicationAddress(jina))
+	if err != nil {
+		return
+	}
+
+	_, err = atc.Execute(algodClient, context.Background(), 2)
+	return
+}
@@ -0,0 +1,44 @@
+package jina
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+func TestAuctionPrice(t *testing.T) {
+	a := Auction{StartRound: 100, StartPrice: 2000, Floor: 1000}
+	cases := map[uint64]uint64{
+		50:                    2000,
+		100:                   2000,
+		100 + AuctionRounds/2: 1500,
+		100 + AuctionRounds:   1000,
+		5000:                  1000,
+	}
+	for round, want := range cases {
+		if got := a.Price(round); got != want {
+			t.Errorf("price at round %d = %d, want %d", round, got, want)
+		}
+	}
+}
+
+func TestDecodeAuction(t *testing.T) {
+	borrower := crypto.GenerateAccount().Address
+	value := make([]byte, 32)
+	binary.BigEndian.PutUint64(value[0:], 100)
+	binary.BigEndian.PutUint64(value[8:], 2000)
+	binary.BigEndian.PutUint64(value[16:], 1000)
+	binary.BigEndian.PutUint64(value[24:], 90)
+
+	a, ok := decodeAuction(auctionKey(borrower, 2), value)
+	if !ok {
+		t.Fatalf("auction not decoded")
+	}
+	if a.Borrower != borrower || a.AssetID != 2 || a.StartRound != 100 || a.StartPrice != 2000 || a.Floor != 1000 || a.LoanStart != 90 {
+		t.Errorf("wrong auction %+v", a)
+	}
+	if _, ok = decodeAuction("mng", value); ok {
+		t.Errorf("decoded auction from unrelated key")
+	}
+}
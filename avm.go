@@ -0,0 +1,777 @@
+//go:build offline
+// +build offline
+
+package jina
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/algorand/go-algorand-sdk/abi"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/encoding/msgpack"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/algorand/go-algorand/config"
+	"github.com/algorand/go-algorand/crypto"
+	"github.com/algorand/go-algorand/data/basics"
+	"github.com/algorand/go-algorand/data/transactions"
+	"github.com/algorand/go-algorand/data/transactions/logic"
+	"github.com/algorand/go-algorand/ledger/apply"
+	"github.com/algorand/go-algorand/ledger/ledgercore"
+	"github.com/algorand/go-algorand/protocol"
+)
+
+// AVM evaluates transaction groups against an in-memory ledger with the transaction and
+// program evaluation of go-algorand: signatures and lsigs, fees, leases, inner transactions
+// and min balances are checked as algod does, and a failing group changes nothing.
+// Contracts are unit tested with it without a node, see avm_test.go
+type AVM struct {
+	Round     uint64 // round the next group is evaluated in
+	Timestamp int64  // latest block timestamp
+	// Proto are the consensus params of the current protocol, tests can relax a limit to get past it
+	Proto config.ConsensusParams
+
+	specials    transactions.SpecialAddresses
+	genesisHash crypto.Digest
+	state       *avmState
+}
+
+// AVMGenesisID is the genesis id of transactions the AVM accepts
+const AVMGenesisID = "jina-avm"
+
+type avmAccount struct {
+	data     ledgercore.AccountData
+	apps     map[basics.AppIndex]basics.AppParams
+	locals   map[basics.AppIndex]basics.AppLocalState
+	assets   map[basics.AssetIndex]basics.AssetParams
+	holdings map[basics.AssetIndex]basics.AssetHolding
+}
+
+type avmLease struct {
+	sender basics.Address
+	lease  [32]byte
+}
+
+type avmState struct {
+	accounts map[basics.Address]*avmAccount
+	creators map[basics.CreatableIndex]basics.Address
+	leases   map[avmLease]basics.Round
+	counter  uint64
+}
+
+// NewAVM makes an empty ledger at round 1
+func NewAVM() *AVM {
+	a := &AVM{
+		Round:     1,
+		Timestamp: 1650000000,
+		Proto:     config.Consensus[protocol.ConsensusCurrentVersion],
+		state: &avmState{
+			accounts: make(map[basics.Address]*avmAccount),
+			creators: make(map[basics.CreatableIndex]basics.Address),
+			leases:   make(map[avmLease]basics.Round),
+		},
+	}
+	a.genesisHash = crypto.Hash([]byte(AVMGenesisID))
+	a.specials.FeeSink = basics.Address(crypto.Hash([]byte("fee sink")))
+	a.specials.RewardsPool = basics.Address(crypto.Hash([]byte("rewards pool")))
+	return a
+}
+
+// SuggestedParams are transaction params valid for the next 1000 rounds, at min fee
+func (a *AVM) SuggestedParams() types.SuggestedParams {
+	return types.SuggestedParams{
+		Fee:             types.MicroAlgos(a.Proto.MinTxnFee),
+		MinFee:          a.Proto.MinTxnFee,
+		FlatFee:         true,
+		FirstRoundValid: types.Round(a.Round),
+		LastRoundValid:  types.Round(a.Round + 1000),
+		GenesisID:       AVMGenesisID,
+		GenesisHash:     a.genesisHash[:],
+	}
+}
+
+// Fund credits an account with microAlgos out of thin air
+func (a *AVM) Fund(addr types.Address, microAlgos uint64) {
+	acct := a.state.account(basics.Address(addr))
+	acct.data.MicroAlgos.Raw += microAlgos
+}
+
+// Advance moves the ledger forward by rounds, with 4.5 second blocks
+func (a *AVM) Advance(rounds uint64) {
+	a.Round += rounds
+	a.Timestamp += int64(rounds) * 9 / 2
+}
+
+// Balance is the microAlgos of an account
+func (a *AVM) Balance(addr types.Address) uint64 {
+	return a.state.get(basics.Address(addr)).data.MicroAlgos.Raw
+}
+
+// AssetBalance is the amount of an asset an account holds, false when not opted in
+func (a *AVM) AssetBalance(addr types.Address, asset uint64) (uint64, bool) {
+	h, ok := a.state.get(basics.Address(addr)).holdings[basics.AssetIndex(asset)]
+	return h.Amount, ok
+}
+
+// Global is the global state of an app keyed by raw key, as read from algod by globalState
+func (a *AVM) Global(app uint64) map[string]models.TealValue {
+	creator, ok := a.state.creators[basics.CreatableIndex(app)]
+	if !ok {
+		return nil
+	}
+	return tealState(a.state.get(creator).apps[basics.AppIndex(app)].GlobalState)
+}
+
+// Local is the local state of an account in an app, nil when not opted in
+func (a *AVM) Local(addr types.Address, app uint64) map[string]models.TealValue {
+	ls, ok := a.state.get(basics.Address(addr)).locals[basics.AppIndex(app)]
+	if !ok {
+		return nil
+	}
+	return tealState(ls.KeyValue)
+}
+
+func tealState(kv basics.TealKeyValue) map[string]models.TealValue {
+	state := make(map[string]models.TealValue, len(kv))
+	for k, v := range kv {
+		state[k] = models.TealValue{Type: uint64(v.Type), Uint: v.Uint, Bytes: base64.StdEncoding.EncodeToString([]byte(v.Bytes))}
+	}
+	return state
+}
+
+// AVMTxn is a transaction the AVM applied, with what algod reports in its pending info
+type AVMTxn struct {
+	Txn              types.Transaction
+	TxID             string
+	ApplicationIndex uint64 // app created
+	AssetIndex       uint64 // asset created
+	Logs             [][]byte
+	Inner            []AVMTxn
+}
+
+// Execute evaluates a signed group in the current round and moves to the next one.
+// Nothing changes when a transaction of the group fails
+func (a *AVM) Execute(stxns []types.SignedTxn) (applied []AVMTxn, err error) {
+	group := make([]transactions.SignedTxnWithAD, len(stxns))
+	for i, stxn := range stxns {
+		if err = protocol.Decode(msgpack.Encode(stxn), &group[i].SignedTxn); err != nil {
+			return nil, fmt.Errorf("txn %d: %v", i, err)
+		}
+	}
+	if err = a.checkGroup(group); err != nil {
+		return
+	}
+
+	saved := a.state.clone()
+	defer func() {
+		if err != nil {
+			a.state = saved
+		}
+	}()
+	ep := logic.NewEvalParams(group, &a.Proto, &a.specials)
+	ledger := &avmLedger{avm: a}
+	ep.Ledger = ledger
+	for gi := range group {
+		txn := group[gi].Txn
+		var ad transactions.ApplyData
+		if err = ledger.Move(txn.Sender, a.specials.FeeSink, txn.Fee, nil, nil); err == nil {
+			if err = apply.Rekey(ledger, &txn); err == nil {
+				err = ledger.apply(txn, &ad, gi, ep, a.state.counter)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("txn %d %s: %v", gi, txn.ID(), err)
+		}
+		ep.RecordAD(gi, ad)
+		a.state.counter++
+		if err = a.checkMinBalances(); err != nil {
+			return nil, fmt.Errorf("txn %d %s: %v", gi, txn.ID(), err)
+		}
+		if txn.Lease != ([32]byte{}) {
+			a.state.leases[avmLease{txn.Sender, txn.Lease}] = txn.LastValid
+		}
+		applied = append(applied, avmTxn(stxns[gi].Txn, txn.ID().String(), ad))
+	}
+	a.Advance(1)
+	return
+}
+
+func avmTxn(txn types.Transaction, txid string, ad transactions.ApplyData) AVMTxn {
+	t := AVMTxn{
+		Txn:              txn,
+		TxID:             txid,
+		ApplicationIndex: uint64(ad.ApplicationID),
+		AssetIndex:       uint64(ad.ConfigAsset),
+	}
+	for _, l := range ad.EvalDelta.Logs {
+		t.Logs = append(t.Logs, []byte(l))
+	}
+	for _, inner := range ad.EvalDelta.InnerTxns {
+		var itxn types.Transaction
+		msgpack.Decode(protocol.Encode(&inner.Txn), &itxn)
+		t.Inner = append(t.Inner, avmTxn(itxn, inner.Txn.ID().String(), inner.ApplyData))
+	}
+	return t
+}
+
+// ExecuteATC signs and evaluates the group of an atomic transaction composer
+func (a *AVM) ExecuteATC(atc *future.AtomicTransactionComposer) ([]AVMTxn, error) {
+	signed, err := atc.GatherSignatures()
+	if err != nil {
+		return nil, err
+	}
+	return a.ExecuteSigned(signed)
+}
+
+// ExecuteSigned evaluates a group of msgpack encoded signed transactions, as sent to algod
+func (a *AVM) ExecuteSigned(signed [][]byte) ([]AVMTxn, error) {
+	stxns := make([]types.SignedTxn, len(signed))
+	for i, b := range signed {
+		if err := msgpack.Decode(b, &stxns[i]); err != nil {
+			return nil, err
+		}
+	}
+	return a.Execute(stxns)
+}
+
+// Call evaluates a method call with its transaction args, as added to an ATC by combine,
+// and decodes its return value
+func (a *AVM) Call(mcp future.AddMethodCallParams) (ret interface{}, applied []AVMTxn, err error) {
+	var atc future.AtomicTransactionComposer
+	if err = atc.AddMethodCall(mcp); err != nil {
+		return
+	}
+	if applied, err = a.ExecuteATC(&atc); err != nil {
+		return
+	}
+	ret, err = methodReturn(mcp.Method, applied[len(applied)-1])
+	return
+}
+
+// methodReturn decodes the return value a method call logged, nil for void methods
+func methodReturn(method abi.Method, call AVMTxn) (interface{}, error) {
+	if method.Returns.IsVoid() {
+		return nil, nil
+	}
+	if len(call.Logs) == 0 || !bytes.HasPrefix(call.Logs[len(call.Logs)-1], abiReturnPrefix) {
+		return nil, fmt.Errorf("%s did not log a return value", method.Name)
+	}
+	t, err := abi.TypeOf(method.Returns.Type)
+	if err != nil {
+		return nil, err
+	}
+	return t.Decode(call.Logs[len(call.Logs)-1][len(abiReturnPrefix):])
+}
+
+var abiReturnPrefix = []byte{0x15, 0x1f, 0x7c, 0x75}
+
+// checkGroup does what algod checks before evaluating: well formed, alive, grouped,
+// signed by the authorizer of the sender, paying the min fee for the group and not leased
+func (a *AVM) checkGroup(group []transactions.SignedTxnWithAD) error {
+	if len(group) == 0 || len(group) > a.Proto.MaxTxGroupSize {
+		return fmt.Errorf("group of %d transactions", len(group))
+	}
+	var g transactions.TxGroup
+	var fees uint64
+	for gi, stxn := range group {
+		txn := stxn.Txn
+		if err := txn.WellFormed(a.specials, a.Proto); err != nil {
+			return fmt.Errorf("txn %d: %v", gi, err)
+		}
+		if err := txn.Alive(avmContext{a}); err != nil {
+			return fmt.Errorf("txn %d: %v", gi, err)
+		}
+		if txn.Group != group[0].Txn.Group {
+			return fmt.Errorf("txn %d: inconsistent group", gi)
+		}
+		if !txn.Group.IsZero() {
+			txn.Group = crypto.Digest{}
+			g.TxGroupHashes = append(g.TxGroupHashes, crypto.HashObj(txn))
+		} else if len(group) > 1 {
+			return fmt.Errorf("txn %d: no group id in a group of %d", gi, len(group))
+		}
+		if lv, ok := a.state.leases[avmLease{txn.Sender, txn.Lease}]; ok && txn.Lease != ([32]byte{}) && basics.Round(a.Round) <= lv {
+			return fmt.Errorf("txn %d: lease of %s in use until round %d", gi, txn.Sender, lv)
+		}
+		fees += txn.Fee.Raw
+	}
+	if g.TxGroupHashes != nil && group[0].Txn.Group != crypto.HashObj(g) {
+		return fmt.Errorf("incomplete group")
+	}
+	if fees < a.Proto.MinTxnFee*uint64(len(group)) {
+		return fmt.Errorf("group pays %d in fees, less than %d", fees, a.Proto.MinTxnFee*uint64(len(group)))
+	}
+
+	ep := logic.NewEvalParams(group, &a.Proto, &a.specials)
+	for gi, stxn := range group {
+		if err := a.checkSig(stxn.SignedTxn, gi, ep); err != nil {
+			return fmt.Errorf("txn %d: %v", gi, err)
+		}
+	}
+	return nil
+}
+
+func (a *AVM) checkSig(stxn transactions.SignedTxn, gi int, ep *logic.EvalParams) error {
+	auth := a.state.get(stxn.Txn.Sender).data.AuthAddr
+	if auth.IsZero() {
+		auth = stxn.Txn.Sender
+	}
+	if stxn.Authorizer() != auth {
+		return fmt.Errorf("should be authorized by %s, not %s", auth, stxn.Authorizer())
+	}
+	if !stxn.Msig.Blank() || !stxn.Lsig.Msig.Blank() {
+		return fmt.Errorf("multisig is not supported")
+	}
+	if stxn.Lsig.Blank() {
+		if !verify(auth, stxn.Txn, stxn.Sig) {
+			return fmt.Errorf("bad signature")
+		}
+		return nil
+	}
+	if stxn.Lsig.Sig == (crypto.Signature{}) {
+		if basics.Address(logic.HashProgram(stxn.Lsig.Logic)) != auth {
+			return fmt.Errorf("lsig program is not the escrow %s", auth)
+		}
+	} else if !verify(auth, logic.Program(stxn.Lsig.Logic), stxn.Lsig.Sig) {
+		return fmt.Errorf("lsig not delegated by %s", auth)
+	}
+	pass, err := logic.EvalSignature(gi, ep)
+	if err != nil {
+		return fmt.Errorf("lsig: %v", err)
+	}
+	if !pass {
+		return fmt.Errorf("rejected by lsig")
+	}
+	return nil
+}
+
+func verify(addr basics.Address, msg crypto.Hashable, sig crypto.Signature) bool {
+	return ed25519.Verify(addr[:], crypto.HashRep(msg), sig[:])
+}
+
+func (a *AVM) checkMinBalances() error {
+	for addr, acct := range a.state.accounts {
+		if addr == a.specials.FeeSink || addr == a.specials.RewardsPool || acct.data.IsZero() {
+			continue
+		}
+		if min := acct.data.MinBalance(&a.Proto).Raw; acct.data.MicroAlgos.Raw < min {
+			return fmt.Errorf("account %s balance %d below min %d", addr, acct.data.MicroAlgos.Raw, min)
+		}
+	}
+	return nil
+}
+
+// avmContext is the transactions.TxnContext transactions must be alive in
+type avmContext struct{ avm *AVM }
+
+func (c avmContext) Round() basics.Round                       { return basics.Round(c.avm.Round) }
+func (c avmContext) ConsensusProtocol() config.ConsensusParams { return c.avm.Proto }
+func (c avmContext) GenesisID() string                         { return AVMGenesisID }
+func (c avmContext) GenesisHash() crypto.Digest                { return c.avm.genesisHash }
+
+func (s *avmState) get(addr basics.Address) *avmAccount {
+	if acct, ok := s.accounts[addr]; ok {
+		return acct
+	}
+	return &avmAccount{}
+}
+
+func (s *avmState) account(addr basics.Address) *avmAccount {
+	acct, ok := s.accounts[addr]
+	if !ok {
+		acct = &avmAccount{}
+		s.accounts[addr] = acct
+	}
+	if acct.apps == nil {
+		acct.apps = make(map[basics.AppIndex]basics.AppParams)
+		acct.locals = make(map[basics.AppIndex]basics.AppLocalState)
+		acct.assets = make(map[basics.AssetIndex]basics.AssetParams)
+		acct.holdings = make(map[basics.AssetIndex]basics.AssetHolding)
+	}
+	return acct
+}
+
+func (s *avmState) clone() *avmState {
+	c := &avmState{
+		accounts: make(map[basics.Address]*avmAccount, len(s.accounts)),
+		creators: make(map[basics.CreatableIndex]basics.Address, len(s.creators)),
+		leases:   make(map[avmLease]basics.Round, len(s.leases)),
+		counter:  s.counter,
+	}
+	for addr, acct := range s.accounts {
+		ca := c.account(addr)
+		ca.data = acct.data
+		for k, v := range acct.apps {
+			ca.apps[k] = v.Clone()
+		}
+		for k, v := range acct.locals {
+			ca.locals[k] = v.Clone()
+		}
+		for k, v := range acct.assets {
+			ca.assets[k] = v
+		}
+		for k, v := range acct.holdings {
+			ca.holdings[k] = v
+		}
+	}
+	for k, v := range s.creators {
+		c.creators[k] = v
+	}
+	for k, v := range s.leases {
+		c.leases[k] = v
+	}
+	return c
+}
+
+// avmLedger is both the apply.Balances transactions are applied to
+// and the logic.LedgerForLogic programs run against
+type avmLedger struct {
+	avm *AVM
+}
+
+func (l *avmLedger) state() *avmState { return l.avm.state }
+
+func (l *avmLedger) apply(txn transactions.Transaction, ad *transactions.ApplyData, gi int, ep *logic.EvalParams, ctr uint64) error {
+	switch txn.Type {
+	case protocol.PaymentTx:
+		return apply.Payment(txn.PaymentTxnFields, txn.Header, l, l.avm.specials, ad)
+	case protocol.AssetConfigTx:
+		return apply.AssetConfig(txn.AssetConfigTxnFields, txn.Header, l, l.avm.specials, ad, ctr)
+	case protocol.AssetTransferTx:
+		return apply.AssetTransfer(txn.AssetTransferTxnFields, txn.Header, l, l.avm.specials, ad)
+	case protocol.AssetFreezeTx:
+		return apply.AssetFreeze(txn.AssetFreezeTxnFields, txn.Header, l, l.avm.specials, ad)
+	case protocol.ApplicationCallTx:
+		return apply.ApplicationCall(txn.ApplicationCallTxnFields, txn.Header, l, ad, gi, ep, ctr)
+	}
+	return fmt.Errorf("%s transactions are not supported", txn.Type)
+}
+
+// apply.Balances
+
+func (l *avmLedger) Get(addr basics.Address, withPendingRewards bool) (ledgercore.AccountData, error) {
+	return l.state().get(addr).data, nil
+}
+
+func (l *avmLedger) Put(addr basics.Address, data ledgercore.AccountData) error {
+	l.state().account(addr).data = data
+	return nil
+}
+
+func (l *avmLedger) CloseAccount(addr basics.Address) error {
+	delete(l.state().accounts, addr)
+	return nil
+}
+
+func (l *avmLedger) GetAppParams(addr basics.Address, aidx basics.AppIndex) (basics.AppParams, bool, error) {
+	p, ok := l.state().get(addr).apps[aidx]
+	return p, ok, nil
+}
+
+func (l *avmLedger) PutAppParams(addr basics.Address, aidx basics.AppIndex, params basics.AppParams) error {
+	l.state().account(addr).apps[aidx] = params
+	return nil
+}
+
+func (l *avmLedger) DeleteAppParams(addr basics.Address, aidx basics.AppIndex) error {
+	delete(l.state().account(addr).apps, aidx)
+	return nil
+}
+
+func (l *avmLedger) GetAppLocalState(addr basics.Address, aidx basics.AppIndex) (basics.AppLocalState, bool, error) {
+	ls, ok := l.state().get(addr).locals[aidx]
+	return ls, ok, nil
+}
+
+func (l *avmLedger) HasAppLocalState(addr basics.Address, aidx basics.AppIndex) (bool, error) {
+	_, ok := l.state().get(addr).locals[aidx]
+	return ok, nil
+}
+
+func (l *avmLedger) PutAppLocalState(addr basics.Address, aidx basics.AppIndex, state basics.AppLocalState) error {
+	l.state().account(addr).locals[aidx] = state
+	return nil
+}
+
+func (l *avmLedger) DeleteAppLocalState(addr basics.Address, aidx basics.AppIndex) error {
+	delete(l.state().account(addr).locals, aidx)
+	return nil
+}
+
+func (l *avmLedger) GetAssetHolding(addr basics.Address, aidx basics.AssetIndex) (basics.AssetHolding, bool, error) {
+	h, ok := l.state().get(addr).holdings[aidx]
+	return h, ok, nil
+}
+
+func (l *avmLedger) PutAssetHolding(addr basics.Address, aidx basics.AssetIndex, data basics.AssetHolding) error {
+	l.state().account(addr).holdings[aidx] = data
+	return nil
+}
+
+func (l *avmLedger) DeleteAssetHolding(addr basics.Address, aidx basics.AssetIndex) error {
+	delete(l.state().account(addr).holdings, aidx)
+	return nil
+}
+
+func (l *avmLedger) GetAssetParams(addr basics.Address, aidx basics.AssetIndex) (basics.AssetParams, bool, error) {
+	p, ok := l.state().get(addr).assets[aidx]
+	return p, ok, nil
+}
+
+func (l *avmLedger) HasAssetParams(addr basics.Address, aidx basics.AssetIndex) (bool, error) {
+	_, ok := l.state().get(addr).assets[aidx]
+	return ok, nil
+}
+
+func (l *avmLedger) PutAssetParams(addr basics.Address, aidx basics.AssetIndex, data basics.AssetParams) error {
+	l.state().account(addr).assets[aidx] = data
+	return nil
+}
+
+func (l *avmLedger) DeleteAssetParams(addr basics.Address, aidx basics.AssetIndex) error {
+	delete(l.state().account(addr).assets, aidx)
+	return nil
+}
+
+func (l *avmLedger) GetCreator(cidx basics.CreatableIndex, ctype basics.CreatableType) (basics.Address, bool, error) {
+	addr, ok := l.state().creators[cidx]
+	return addr, ok, nil
+}
+
+func (l *avmLedger) AllocateApp(addr basics.Address, aidx basics.AppIndex, global bool, space basics.StateSchema) error {
+	if global {
+		l.state().creators[basics.CreatableIndex(aidx)] = addr
+	}
+	return nil
+}
+
+func (l *avmLedger) DeallocateApp(addr basics.Address, aidx basics.AppIndex, global bool) error {
+	if global {
+		delete(l.state().creators, basics.CreatableIndex(aidx))
+	}
+	return nil
+}
+
+func (l *avmLedger) AllocateAsset(addr basics.Address, index basics.AssetIndex, global bool) error {
+	if global {
+		l.state().creators[basics.CreatableIndex(index)] = addr
+	}
+	return nil
+}
+
+func (l *avmLedger) DeallocateAsset(addr basics.Address, index basics.AssetIndex, global bool) error {
+	if global {
+		delete(l.state().creators, basics.CreatableIndex(index))
+	}
+	return nil
+}
+
+// StatefulEval runs an app program, keeping its changes only when it passes
+func (l *avmLedger) StatefulEval(gi int, params *logic.EvalParams, aidx basics.AppIndex, program []byte) (pass bool, evalDelta transactions.EvalDelta, err error) {
+	saved := l.state().clone()
+	params.Ledger = l
+	pass, cx, err := logic.EvalContract(program, gi, aidx, params)
+	if err != nil {
+		l.avm.state = saved
+		var details string
+		if cx != nil {
+			pc, det := cx.PcDetails()
+			details = fmt.Sprintf("pc=%d, opcodes=%s", pc, det)
+		}
+		if _, ok := err.(logic.ClearStateBudgetError); ok {
+			return false, transactions.EvalDelta{}, err
+		}
+		return false, transactions.EvalDelta{}, ledgercore.LogicEvalError{Err: err, Details: details}
+	}
+	if !pass {
+		l.avm.state = saved
+		return
+	}
+	return true, params.TxnGroup[gi].EvalDelta, nil
+}
+
+func (l *avmLedger) Move(src, dst basics.Address, amount basics.MicroAlgos, srcRewards *basics.MicroAlgos, dstRewards *basics.MicroAlgos) error {
+	from := l.state().account(src)
+	if from.data.MicroAlgos.Raw < amount.Raw {
+		return fmt.Errorf("overspend (account %s, data %d, tried to spend %d)", src, from.data.MicroAlgos.Raw, amount.Raw)
+	}
+	from.data.MicroAlgos.Raw -= amount.Raw
+	to := l.state().account(dst)
+	sum, overflow := basics.OAdd(to.data.MicroAlgos.Raw, amount.Raw)
+	if overflow {
+		return fmt.Errorf("balance overflow (account %s)", dst)
+	}
+	to.data.MicroAlgos.Raw = sum
+	return nil
+}
+
+func (l *avmLedger) ConsensusParams() config.ConsensusParams {
+	return l.avm.Proto
+}
+
+// logic.LedgerForLogic
+
+func (l *avmLedger) AccountData(addr basics.Address) (ledgercore.AccountData, error) {
+	return l.state().get(addr).data, nil
+}
+
+func (l *avmLedger) Authorizer(addr basics.Address) (basics.Address, error) {
+	if auth := l.state().get(addr).data.AuthAddr; !auth.IsZero() {
+		return auth, nil
+	}
+	return addr, nil
+}
+
+func (l *avmLedger) Round() basics.Round {
+	return basics.Round(l.avm.Round)
+}
+
+func (l *avmLedger) LatestTimestamp() int64 {
+	return l.avm.Timestamp
+}
+
+func (l *avmLedger) AssetHolding(addr basics.Address, aidx basics.AssetIndex) (basics.AssetHolding, error) {
+	h, ok := l.state().get(addr).holdings[aidx]
+	if !ok {
+		return h, fmt.Errorf("account %s has not opted in to asset %d", addr, aidx)
+	}
+	return h, nil
+}
+
+func (l *avmLedger) AssetParams(aidx basics.AssetIndex) (basics.AssetParams, basics.Address, error) {
+	creator, ok := l.state().creators[basics.CreatableIndex(aidx)]
+	if !ok {
+		return basics.AssetParams{}, creator, fmt.Errorf("asset %d does not exist", aidx)
+	}
+	p, ok := l.state().get(creator).assets[aidx]
+	if !ok {
+		return basics.AssetParams{}, creator, fmt.Errorf("asset %d does not exist", aidx)
+	}
+	return p, creator, nil
+}
+
+func (l *avmLedger) AppParams(aidx basics.AppIndex) (basics.AppParams, basics.Address, error) {
+	creator, ok := l.state().creators[basics.CreatableIndex(aidx)]
+	if !ok {
+		return basics.AppParams{}, creator, fmt.Errorf("app %d does not exist", aidx)
+	}
+	p, ok := l.state().get(creator).apps[aidx]
+	if !ok {
+		return basics.AppParams{}, creator, fmt.Errorf("app %d does not exist", aidx)
+	}
+	return p, creator, nil
+}
+
+func (l *avmLedger) OptedIn(addr basics.Address, aidx basics.AppIndex) (bool, error) {
+	return l.HasAppLocalState(addr, aidx)
+}
+
+func (l *avmLedger) GetLocal(addr basics.Address, aidx basics.AppIndex, key string, accountIdx uint64) (basics.TealValue, bool, error) {
+	ls, ok := l.state().get(addr).locals[aidx]
+	if !ok {
+		return basics.TealValue{}, false, fmt.Errorf("%s has not opted in to app %d", addr, aidx)
+	}
+	v, ok := ls.KeyValue[key]
+	return v, ok, nil
+}
+
+func (l *avmLedger) SetLocal(addr basics.Address, aidx basics.AppIndex, key string, value basics.TealValue, accountIdx uint64) error {
+	acct := l.state().get(addr)
+	ls, ok := acct.locals[aidx]
+	if !ok {
+		return fmt.Errorf("%s has not opted in to app %d", addr, aidx)
+	}
+	if ls.KeyValue == nil {
+		ls.KeyValue = make(basics.TealKeyValue)
+	}
+	ls.KeyValue[key] = value
+	acct.locals[aidx] = ls
+	return checkSchema(ls.KeyValue, ls.Schema)
+}
+
+func (l *avmLedger) DelLocal(addr basics.Address, aidx basics.AppIndex, key string, accountIdx uint64) error {
+	ls, ok := l.state().get(addr).locals[aidx]
+	if !ok {
+		return fmt.Errorf("%s has not opted in to app %d", addr, aidx)
+	}
+	delete(ls.KeyValue, key)
+	return nil
+}
+
+func (l *avmLedger) app(aidx basics.AppIndex) (*avmAccount, basics.AppParams, error) {
+	creator, ok := l.state().creators[basics.CreatableIndex(aidx)]
+	if !ok {
+		return nil, basics.AppParams{}, fmt.Errorf("app %d does not exist", aidx)
+	}
+	acct := l.state().get(creator)
+	return acct, acct.apps[aidx], nil
+}
+
+func (l *avmLedger) GetGlobal(aidx basics.AppIndex, key string) (basics.TealValue, bool, error) {
+	_, p, err := l.app(aidx)
+	if err != nil {
+		return basics.TealValue{}, false, err
+	}
+	v, ok := p.GlobalState[key]
+	return v, ok, nil
+}
+
+func (l *avmLedger) SetGlobal(aidx basics.AppIndex, key string, value basics.TealValue) error {
+	acct, p, err := l.app(aidx)
+	if err != nil {
+		return err
+	}
+	if p.GlobalState == nil {
+		p.GlobalState = make(basics.TealKeyValue)
+	}
+	p.GlobalState[key] = value
+	acct.apps[aidx] = p
+	return checkSchema(p.GlobalState, p.GlobalStateSchema)
+}
+
+func (l *avmLedger) DelGlobal(aidx basics.AppIndex, key string) error {
+	_, p, err := l.app(aidx)
+	if err != nil {
+		return err
+	}
+	delete(p.GlobalState, key)
+	return nil
+}
+
+func checkSchema(kv basics.TealKeyValue, schema basics.StateSchema) error {
+	var uints, byteslices uint64
+	for _, v := range kv {
+		if v.Type == basics.TealUintType {
+			uints++
+		} else {
+			byteslices++
+		}
+	}
+	if uints > schema.NumUint {
+		return fmt.Errorf("store integer count %d exceeds schema integer count %d", uints, schema.NumUint)
+	}
+	if byteslices > schema.NumByteSlice {
+		return fmt.Errorf("store bytes count %d exceeds schema bytes count %d", byteslices, schema.NumByteSlice)
+	}
+	return nil
+}
+
+// Perform applies an inner transaction, as the ledger does for itxn_submit
+func (l *avmLedger) Perform(gi int, ep *logic.EvalParams) error {
+	txn := &ep.TxnGroup[gi]
+	if err := l.Move(txn.Txn.Sender, ep.Specials.FeeSink, txn.Txn.Fee, nil, nil); err != nil {
+		return err
+	}
+	if err := apply.Rekey(l, &txn.Txn); err != nil {
+		return err
+	}
+	l.state().counter++
+	return l.apply(txn.Txn, &txn.ApplyData, gi, ep, l.state().counter)
+}
+
+func (l *avmLedger) Counter() uint64 {
+	return l.state().counter
+}
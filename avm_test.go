@@ -0,0 +1,940 @@
+//go:build offline
+// +build offline
+
+package jina
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/abi"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+	"github.com/algorand/go-algorand/data/basics"
+)
+
+// market is jina deployed on an AVM, what the sandbox tests in jina_test.go set up on a node
+type market struct {
+	t     *testing.T
+	avm   *AVM
+	admin crypto.Account
+
+	manager, jina, lqt                    *abi.Contract
+	mng, jinaApp, lqtApp, usdc, jusd, jna uint64
+}
+
+func tealProgram(t *testing.T, osTealFile string) []byte {
+	tealFile, err := ReadTemplate(osTealFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AssembleOffline(tealFile)
+	if err != nil {
+		t.Fatalf("%s: %v", osTealFile, err)
+	}
+	return p
+}
+
+func abiContract(t *testing.T, file string) *abi.Contract {
+	c, err := getContract(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (m *market) account(microAlgos uint64) crypto.Account {
+	acct := crypto.GenerateAccount()
+	m.avm.Fund(acct.Address, microAlgos)
+	return acct
+}
+
+// call makes an app call from acct with fee times the min fee, failing the test when it is rejected
+func (m *market) call(acct crypto.Account, app uint64, c *abi.Contract, method string, fee uint64, args ...interface{}) interface{} {
+	m.t.Helper()
+	ret, _, err := m.avm.Call(m.mcp(acct, app, c, method, fee, args...))
+	if err != nil {
+		m.t.Fatalf("%s: %v", method, err)
+	}
+	return ret
+}
+
+func (m *market) mcp(acct crypto.Account, app uint64, c *abi.Contract, method string, fee uint64, args ...interface{}) future.AddMethodCallParams {
+	sp := m.avm.SuggestedParams()
+	sp.Fee = types.MicroAlgos(fee * sp.MinFee)
+	mcp := future.AddMethodCallParams{
+		AppID:           app,
+		Sender:          acct.Address,
+		SuggestedParams: sp,
+		OnComplete:      types.NoOpOC,
+		Signer:          future.BasicAccountTransactionSigner{Account: acct},
+	}
+	return combine(mcp, getMethod(c, method), args)
+}
+
+// axfer is an asset transfer paid for by the app call it goes with
+func (m *market) axfer(from crypto.Account, to types.Address, amt, asset uint64) future.TransactionWithSigner {
+	sp := m.avm.SuggestedParams()
+	sp.Fee = 0
+	txn, err := future.MakeAssetTransferTxn(from.Address.String(), to.String(), amt, nil, sp, "", asset)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return future.TransactionWithSigner{Txn: txn, Signer: future.BasicAccountTransactionSigner{Account: from}}
+}
+
+func (m *market) send(acct crypto.Account, txn types.Transaction) []AVMTxn {
+	m.t.Helper()
+	_, stxn, err := crypto.SignTransaction(acct.PrivateKey, txn)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	applied, err := m.avm.ExecuteSigned([][]byte{stxn})
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return applied
+}
+
+func (m *market) createASA(acct crypto.Account, amt uint64, name string) uint64 {
+	addr := acct.Address.String()
+	txn, err := future.MakeAssetCreateTxn(addr, nil, m.avm.SuggestedParams(), amt, 6, false, addr, addr, addr, addr, name, name, "", "")
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return m.send(acct, txn)[0].AssetIndex
+}
+
+func (m *market) optinASA(acct crypto.Account, asset uint64) {
+	txn, err := future.MakeAssetTransferTxn(acct.Address.String(), acct.Address.String(), 0, nil, m.avm.SuggestedParams(), "", asset)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	m.send(acct, txn)
+}
+
+func (m *market) transfer(from crypto.Account, to types.Address, amt, asset uint64) {
+	txn, err := future.MakeAssetTransferTxn(from.Address.String(), to.String(), amt, nil, m.avm.SuggestedParams(), "", asset)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	m.send(from, txn)
+}
+
+// deploy creates the manager, funds it and creates and configures its child apps, as Deploy, CreateApps and ConfigureApps
+func deploy(t *testing.T) *market {
+	m := &market{t: t, avm: NewAVM()}
+	m.admin = m.account(1000000000)
+	m.manager = abiContract(t, "./abi/manager.json")
+	m.jina = abiContract(t, "./abi/jina.json")
+	m.lqt = abiContract(t, "./abi/lqt.json")
+	m.usdc = m.createASA(m.admin, 1e15, "USDC")
+
+	mcp := m.mcp(m.admin, 0, m.manager, "create", 1, m.usdc)
+	mcp.ApprovalProgram = tealProgram(t, "./teal/managerApp.teal")
+	mcp.ClearProgram = tealProgram(t, "./teal/clearState.teal")
+	mcp.GlobalSchema = types.StateSchema{NumUint: 32, NumByteSlice: 32}
+	mcp.LocalSchema = types.StateSchema{NumUint: 0, NumByteSlice: 1}
+	mcp.ExtraPages = 3
+	_, applied, err := m.avm.Call(mcp)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	m.mng = applied[0].ApplicationIndex
+	m.avm.Fund(crypto.GetApplicationAddress(m.mng), 10000000)
+
+	m.lqtApp = m.call(m.admin, m.mng, m.manager, "create_liquidator", 2,
+		m.usdc, tealProgram(t, "./teal/liquidatorApp.teal"), tealProgram(t, "./teal/clearState.teal")).(uint64)
+
+	// the jina approval program is over the 2048 bytes of app args, its start goes in program_chunk calls
+	var atc future.AtomicTransactionComposer
+	jinaEnd, err := addProgramChunks(&atc, m.mcp(m.admin, m.mng, m.manager, "program_chunk", 1, []byte{}), m.manager, tealProgram(t, "./teal/jinaApp.teal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := m.mcp(m.admin, m.mng, m.manager, "create_child", 4, m.usdc, jinaEnd, tealProgram(t, "./teal/jinaClear.teal"), m.lqtApp)
+	if err = atc.AddMethodCall(create); err != nil {
+		t.Fatal(err)
+	}
+	applied, err = m.avm.ExecuteATC(&atc)
+	if err != nil {
+		t.Fatalf("create_child: %v", err)
+	}
+	ret, err := methodReturn(create.Method, applied[len(applied)-1])
+	if err != nil {
+		t.Fatalf("create_child: %v", err)
+	}
+	ids := ret.([]interface{})
+	if len(applied) < 2 {
+		t.Errorf("jina approval program passed without program chunks")
+	}
+	m.jinaApp, m.jusd, m.jna = ids[0].(uint64), ids[1].(uint64), ids[2].(uint64)
+	m.call(m.admin, m.mng, m.manager, "config", 12,
+		m.lqtApp, m.jinaApp, crypto.GetApplicationAddress(m.lqtApp), crypto.GetApplicationAddress(m.jinaApp), m.usdc, m.jusd)
+	return m
+}
+
+func TestAVMDeploy(t *testing.T) {
+	m := deploy(t)
+	if m.jinaApp == 0 || m.lqtApp == 0 || m.jusd == 0 || m.jna == 0 {
+		t.Fatalf("create_child returned %d %d %d, liquidator %d", m.jinaApp, m.jusd, m.jna, m.lqtApp)
+	}
+	if _, ok := m.avm.AssetBalance(crypto.GetApplicationAddress(m.jinaApp), m.usdc); !ok {
+		t.Errorf("jina not opted in to usdc")
+	}
+	if g := m.avm.Global(m.mng); len(g) == 0 {
+		t.Errorf("manager has no global state")
+	}
+}
+
+func TestAVMPrice(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	// the asset arg selects the price key, whatever its place in the foreign assets
+	itob := func(v uint64) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		return b
+	}
+	price := getMethod(m.manager, "price")
+	args := [][]byte{price.GetSelector(), itob(1), itob(700000)}
+	txn, err := future.MakeApplicationNoOpTx(m.mng, args, nil, nil, []uint64{m.usdc, xaid}, m.avm.SuggestedParams(), m.admin.Address, nil, types.Digest{}, [32]byte{}, types.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.send(m.admin, txn)
+	g := m.avm.Global(m.mng)
+	if v := stateBytes(g[string(itob(xaid))]); len(v) < 16 || binary.BigEndian.Uint64(v) != 700000 {
+		t.Errorf("price of the collateral %x, want 700000", v)
+	}
+	if _, ok := g[string(itob(m.usdc))]; ok {
+		t.Errorf("price set for the first foreign asset")
+	}
+}
+
+// lend sets up a lender with an offer for collateral xaid and its signed lsig
+func (m *market) lend(xaid, aamt uint64) (crypto.Account, LenderLsig) {
+	lender := m.account(10000000)
+	m.optinASA(lender, m.usdc)
+	m.optinASA(lender, m.jusd)
+	m.transfer(m.admin, lender.Address, aamt, m.usdc)
+	m.optin(lender)
+	terms := LenderTerms{USDCa: m.usdc, Amount: aamt, LastValid: m.avm.Round + 10000, Jina: m.jinaApp}
+	l, err := SignLenderLsig(lenderProgramOffline(m.t, terms, lender.Address), terms, AccountSigner{lender})
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	lsa := l.OfferID()
+	m.call(lender, m.jinaApp, m.jina, "earn", 1, []uint64{xaid}, aamt, terms.LastValid, lsa[:], DefaultFeeRate, uint64(0), m.mng)
+	return lender, l
+}
+
+func TestAVMEarnTerms(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	lender := m.account(10000000)
+	m.optin(lender)
+	earn := func(feeRate, maxLTV uint64) error {
+		_, _, err := m.avm.Call(m.mcp(lender, m.jinaApp, m.jina, "earn", 1, []uint64{xaid}, uint64(1000000), m.avm.Round+1000, make([]byte, 32), feeRate, maxLTV, m.mng))
+		return err
+	}
+	if err := earn(10000, 0); err == nil {
+		t.Errorf("earned with a fee of the whole loan")
+	}
+	if err := earn(9999, 0); err != nil {
+		t.Errorf("earn: %v", err)
+	}
+
+	// max ltv is capped by the manager's liquidation threshold
+	r := DefaultRiskParams
+	r.Threshold = 80
+	m.call(m.admin, m.mng, m.manager, "set_risk", 1, r.Threshold, r.FeeRate, r.Payment, r.Reference, r.MaxAge)
+	if err := earn(DefaultFeeRate, 85); err == nil {
+		t.Errorf("earned with a max ltv above the threshold")
+	}
+	if err := earn(DefaultFeeRate, 80); err != nil {
+		t.Errorf("earn: %v", err)
+	}
+}
+
+func (m *market) optin(acct crypto.Account) {
+	mcp := m.mcp(acct, m.jinaApp, m.jina, "optin", 1, m.mng)
+	mcp.OnComplete = types.OptInOC
+	if _, _, err := m.avm.Call(mcp); err != nil {
+		m.t.Fatalf("optin: %v", err)
+	}
+}
+
+// collateral creates an asset administered by the market apps, as ConfigASA, listed in the manager registry at price
+func (m *market) collateral(price uint64) uint64 {
+	addr := m.admin.Address.String()
+	txn, err := future.MakeAssetCreateTxn(addr, nil, m.avm.SuggestedParams(), 1000, 0, false,
+		crypto.GetApplicationAddress(m.mng).String(), addr, crypto.GetApplicationAddress(m.jinaApp).String(), crypto.GetApplicationAddress(m.lqtApp).String(), "NFT", "NFT", "", "")
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	xaid := m.send(m.admin, txn)[0].AssetIndex
+	m.call(m.admin, m.mng, m.manager, "set_collateral", 1, xaid, uint64(80), uint64(0), uint64(0))
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, price)
+	return xaid
+}
+
+// borrower holds camt of the collateral and usdc to pay fees back
+func (m *market) borrower(xaid, camt, usdc uint64) crypto.Account {
+	b := m.account(10000000)
+	m.optinASA(b, m.usdc)
+	m.optinASA(b, xaid)
+	m.transfer(m.admin, b.Address, camt, xaid)
+	m.transfer(m.admin, b.Address, usdc, m.usdc)
+	m.optin(b)
+	return b
+}
+
+// lenderTransfer is a transfer of the lender's USDCa signed by its delegated lsig, with the offer id as note
+func (m *market) lenderTransfer(lender LenderLsig, to types.Address, amt uint64) future.TransactionWithSigner {
+	sp := m.avm.SuggestedParams()
+	sp.Fee = 0
+	txn, err := future.MakeAssetTransferTxn(lender.Lender.String(), to.String(), amt, nil, sp, "", m.usdc)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	lsa := lender.OfferID()
+	txn.Note = lsa[:]
+	return future.TransactionWithSigner{Txn: txn, Signer: future.LogicSigAccountTransactionSigner{LogicSigAccount: lender.Lsig}}
+}
+
+func (m *market) borrowMCP(b crypto.Account, lender LenderLsig, xaid, camt, lamt uint64) future.AddMethodCallParams {
+	stxn := m.lenderTransfer(lender, b.Address, lamt)
+	args := append([]interface{}{stxn, []uint64{xaid}, []uint64{camt}, []uint64{lamt}, lender.Lender, xaid, m.jusd, m.mng, m.lqtApp}, SignedPrice{}.args()...)
+	return m.mcp(b, m.jinaApp, m.jina, "borrow", 5, args...)
+}
+
+func (m *market) borrow(b crypto.Account, lender LenderLsig, xaid, camt, lamt uint64) {
+	m.t.Helper()
+	if _, _, err := m.avm.Call(m.borrowMCP(b, lender, xaid, camt, lamt)); err != nil {
+		m.t.Fatalf("borrow: %v", err)
+	}
+}
+
+// repay pays back a loan and the head of the redemption queue, as Repay does
+func (m *market) repay(b crypto.Account, xaid, ramt uint64) {
+	m.t.Helper()
+	stxn := m.axfer(b, crypto.GetApplicationAddress(m.jinaApp), ramt, m.usdc)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddMethodCall(m.mcp(b, m.jinaApp, m.jina, "repay", 3, stxn, []uint64{xaid}, []uint64{ramt}, xaid, m.mng, m.lqtApp)); err != nil {
+		m.t.Fatal(err)
+	}
+	if q := redemptionQueue(m.avm.Global(m.jinaApp)); len(q.Redemptions) > 0 {
+		if err := atc.AddMethodCall(m.mcp(b, m.jinaApp, m.jina, "process_queue", 2, q.Redemptions[0].Claimant, m.usdc, m.mng)); err != nil {
+			m.t.Fatal(err)
+		}
+	}
+	if _, err := m.avm.ExecuteATC(&atc); err != nil {
+		m.t.Fatalf("repay: %v", err)
+	}
+}
+
+func (m *market) balance(acct crypto.Account, asset uint64) uint64 {
+	amt, _ := m.avm.AssetBalance(acct.Address, asset)
+	return amt
+}
+
+// loan is the first of the uint64s of a loan key in jina local state, the loan of the first collateral
+func (m *market) loan(acct crypto.Account, key string) uint64 {
+	vals := uint64s(stateBytes(m.avm.Local(acct.Address, m.jinaApp)[key]))
+	if len(vals) == 0 {
+		m.t.Fatalf("no %s in local state of %s", key, acct.Address)
+	}
+	return vals[0]
+}
+
+func TestAVMLoanCycle(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	lender, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 1000000)
+
+	m.borrow(b, l, xaid, 20, 10000000)
+	if got := m.balance(b, m.usdc); got != 11000000 {
+		t.Errorf("borrower has %d usdc, want 11000000", got)
+	}
+	fee := uint64(10000000 * DefaultFeeRate / 10000)
+	if got := m.balance(lender, m.jusd); got != 10000000+fee {
+		t.Errorf("lender has %d jusd, want the loan and its fee", got)
+	}
+	if got := m.loan(b, "lamt"); got != 10000000+fee {
+		t.Errorf("loan %d, want %d", got, 10000000+fee)
+	}
+	if got := m.avm.Local(lender.Address, m.jinaApp)["aamt"].Uint; got != 90000000 {
+		t.Errorf("lender offer %d, want 90000000 left", got)
+	}
+
+	// frozen collateral can not leave the borrower
+	txn, _ := future.MakeAssetTransferTxn(b.Address.String(), m.admin.Address.String(), 20, nil, m.avm.SuggestedParams(), "", xaid)
+	_, stxn, _ := crypto.SignTransaction(b.PrivateKey, txn)
+	if _, err := m.avm.ExecuteSigned([][]byte{stxn}); err == nil {
+		t.Fatalf("moved frozen collateral")
+	}
+
+	// JUSD is claimed for the USDCa jina holds, none until the loan is repaid
+	claim := func(amt uint64) error {
+		_, _, err := m.avm.Call(m.mcp(lender, m.jinaApp, m.jina, "claim", 3, m.axfer(lender, crypto.GetApplicationAddress(m.jinaApp), amt, m.jusd), m.usdc, m.mng))
+		return err
+	}
+	if err := claim(1); err == nil {
+		t.Fatalf("claimed USDCa jina does not hold")
+	}
+
+	m.repay(b, xaid, 10000000+fee)
+	if got := m.loan(b, "lamt"); got != 0 {
+		t.Errorf("loan %d after repaying it", got)
+	}
+	if _, err := m.avm.ExecuteSigned([][]byte{stxn}); err != nil {
+		t.Errorf("collateral still frozen after repay: %v", err)
+	}
+
+	// the lender claims the repaid USDCa for its JUSD
+	if err := claim(10000000 + fee); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if got := m.balance(lender, m.usdc); got != 100000000+fee {
+		t.Errorf("lender has %d usdc after claiming, want %d", got, 100000000+fee)
+	}
+	if got := m.avm.Local(lender.Address, m.jinaApp)["aamt"].Uint; got != 100000000+fee {
+		t.Errorf("lender offer %d after claiming, want the claim restaked", got)
+	}
+}
+
+// liquidate pays for the collateral of a loan, adding jina's address to the call accounts as Liquidate does
+func (m *market) liquidate(liquidator crypto.Account, b crypto.Account, xaid, pay uint64) error {
+	stxn := m.axfer(liquidator, crypto.GetApplicationAddress(m.lqtApp), pay, m.usdc)
+	args := append([]interface{}{stxn, b.Address, liquidator.Address, xaid, m.usdc, m.mng, m.jinaApp}, SignedPrice{}.args()...)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddMethodCall(m.mcp(liquidator, m.lqtApp, m.lqt, "liquidate", 4, args...)); err != nil {
+		return err
+	}
+	atc, err := withAccounts(atc, 1, crypto.GetApplicationAddress(m.jinaApp))
+	if err != nil {
+		return err
+	}
+	_, err = m.avm.ExecuteATC(&atc)
+	return err
+}
+
+func TestAVMLiquidate(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 0)
+	m.borrow(b, l, xaid, 20, 10000000)
+	lamt := m.loan(b, "lamt")
+
+	liquidator := m.borrower(xaid, 0, 20000000)
+	pay := lamt * 105 / 100
+	if err := m.liquidate(liquidator, b, xaid, pay); err == nil {
+		t.Fatalf("liquidated a healthy loan")
+	}
+
+	// at half the price 20 units cover 9000000 at the 90% threshold, less than the loan
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, uint64(500000))
+	if err := m.liquidate(liquidator, b, xaid, pay-1); err == nil {
+		t.Errorf("liquidated paying less than the liquidation payment")
+	}
+	jinaUSDC, _ := m.avm.AssetBalance(crypto.GetApplicationAddress(m.jinaApp), m.usdc)
+	if err := m.liquidate(liquidator, b, xaid, pay); err != nil {
+		t.Fatalf("liquidate: %v", err)
+	}
+	if got := m.balance(liquidator, xaid); got != 20 {
+		t.Errorf("liquidator has %d of the collateral, want 20", got)
+	}
+	if got := m.balance(b, xaid); got != 0 {
+		t.Errorf("borrower kept %d of the collateral", got)
+	}
+	if got, _ := m.avm.AssetBalance(crypto.GetApplicationAddress(m.jinaApp), m.usdc); got != jinaUSDC+lamt {
+		t.Errorf("jina received %d usdc, want the loan %d", got-jinaUSDC, lamt)
+	}
+}
+
+func TestAVMLenderLsig(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	lender, l := m.lend(xaid, 5000000)
+	b := m.borrower(xaid, 20, 0)
+
+	// the lsig lends no more than its amount, and nothing of the rejected group is applied
+	if _, _, err := m.avm.Call(m.borrowMCP(b, l, xaid, 20, 5000001)); err == nil {
+		t.Fatalf("borrowed more than the lsig lends")
+	}
+	if got := m.balance(b, m.usdc); got != 0 {
+		t.Errorf("borrower received %d usdc from a rejected group", got)
+	}
+	if got := m.balance(b, xaid); got != 20 {
+		t.Fatalf("borrower has %d of its collateral after a rejected group", got)
+	}
+	// lsig args are not signed, rewriting them neither raises the amount nor the expiry
+	forged := l
+	forged.Lsig.Lsig.Args = LenderTerms{USDCa: m.usdc, Amount: 100000000, LastValid: l.Terms.LastValid + 100000, Jina: m.jinaApp}.Args()
+	if _, _, err := m.avm.Call(m.borrowMCP(b, forged, xaid, 20, 5000001)); err == nil {
+		t.Fatalf("borrowed more than the lsig lends with forged args")
+	}
+
+	// the lsig only pays out to a borrow, not to any other jina call of the group
+	attacker := m.account(10000000)
+	m.optinASA(attacker, m.usdc)
+	m.optin(attacker)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddTransaction(m.lenderTransfer(l, attacker.Address, 5000000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := atc.AddMethodCall(m.mcp(attacker, m.jinaApp, m.jina, "cancel_offer", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.avm.ExecuteATC(&atc); err == nil || m.balance(attacker, m.usdc) != 0 {
+		t.Fatalf("lsig paid out to cancel_offer, %v", err)
+	}
+
+	// the offer is not locked by its first borrow
+	m.borrow(b, l, xaid, 10, 2000000)
+	m.borrow(b, l, xaid, 10, 2000000)
+	if got := m.balance(b, m.usdc); got != 4000000 {
+		t.Errorf("borrower has %d usdc after two borrows, want 4000000", got)
+	}
+
+	// a new offer invalidates the lsig of the previous one
+	lsa := LenderTerms{USDCa: m.usdc, Amount: 5000000, LastValid: l.Terms.LastValid + 1, Jina: m.jinaApp}.OfferID(lender.Address)
+	m.call(lender, m.jinaApp, m.jina, "update_offer", 1, []uint64{xaid}, uint64(1000000), l.Terms.LastValid+1, lsa[:])
+	if _, _, err := m.avm.Call(m.borrowMCP(b, l, xaid, 0, 100000)); err == nil {
+		t.Errorf("borrowed with the lsig of a replaced offer")
+	}
+}
+
+func TestAVMPause(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	m.call(m.admin, m.mng, m.manager, "set_rate", 1, uint64(1000))
+	m.call(m.admin, m.mng, m.manager, "set_loan_terms", 1, uint64(1000), uint64(100))
+	b := m.borrower(xaid, 20, 1000000)
+	m.borrow(b, l, xaid, 20, 10000000)
+	lamt, start := m.loan(b, "lamt"), m.loan(b, "lrnd")
+	other := m.borrower(xaid, 20, 0)
+	liquidator := m.borrower(xaid, 0, 20000000)
+
+	m.avm.Advance(100)
+	paused := m.avm.Round
+	m.call(m.admin, m.mng, m.manager, "pause", 1)
+	if _, _, err := m.avm.Call(m.borrowMCP(other, l, xaid, 20, 1000000)); err == nil {
+		t.Errorf("borrowed while paused")
+	}
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, uint64(500000))
+	if err := m.liquidate(liquidator, b, xaid, lamt*11/10); err == nil {
+		t.Errorf("liquidated while paused")
+	}
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, uint64(1000000))
+
+	// the loan neither accrues interest nor matures over a pause longer than its term and grace
+	m.avm.Advance(2000)
+	unpaused := m.avm.Round
+	m.call(m.admin, m.mng, m.manager, "unpause", 1)
+	m.avm.Advance(50)
+	global := m.avm.Global(m.mng)
+	round := loanRound(global["paused"].Uint, global["idle"].Uint, m.avm.Round)
+	if want := m.avm.Round - (unpaused - paused); round != want || round > start+1100 {
+		t.Fatalf("loan round %d, want %d", round, want)
+	}
+	if err := m.liquidate(liquidator, b, xaid, lamt*11/10); err == nil {
+		t.Errorf("liquidated a loan overdue only counting the pause")
+	}
+	m.repay(b, xaid, 1)
+	if got, want := m.loan(b, "lamt"), AccruedDebt(lamt, 1000, start, round)-1; got != want {
+		t.Errorf("loan %d after repaying 1, want %d accrued outside the pause", got, want)
+	}
+	m.borrow(other, l, xaid, 20, 1000000)
+}
+
+// grantJNA moves JNA out of the manager to an opted in account, as a JNA distribution would
+func (m *market) grantJNA(to crypto.Account, amt uint64) {
+	for addr, delta := range map[types.Address]int64{crypto.GetApplicationAddress(m.mng): -int64(amt), to.Address: int64(amt)} {
+		holdings := m.avm.state.account(basics.Address(addr)).holdings
+		h := holdings[basics.AssetIndex(m.jna)]
+		h.Amount = uint64(int64(h.Amount) + delta)
+		holdings[basics.AssetIndex(m.jna)] = h
+	}
+}
+
+func TestAVMGovernance(t *testing.T) {
+	m := deploy(t)
+	setRate := func(acct crypto.Account, rate uint64) error {
+		_, _, err := m.avm.Call(m.mcp(acct, m.mng, m.manager, "set_rate", 1, rate))
+		return err
+	}
+	if _, _, err := m.avm.Call(m.mcp(m.admin, m.mng, m.manager, "set_governance", 1, uint64(0), uint64(100))); err == nil {
+		t.Errorf("set a zero quorum")
+	}
+	m.call(m.admin, m.mng, m.manager, "set_governance", 1, uint64(400), uint64(100))
+	if err := setRate(m.admin, 5); err != nil {
+		t.Fatalf("creator could not set the rate before governance: %v", err)
+	}
+
+	m.call(m.admin, m.mng, m.manager, "enable_governance", 1)
+	if err := setRate(m.admin, 6); err == nil {
+		t.Errorf("creator set the rate after enabling governance")
+	}
+	if _, _, err := m.avm.Call(m.mcp(m.admin, m.mng, m.manager, "set_governance", 1, uint64(1), uint64(100))); err == nil {
+		t.Errorf("creator set the quorum after enabling governance")
+	}
+
+	// a passed proposal executes the change
+	voter := m.account(10000000)
+	m.optinASA(voter, m.jna)
+	m.grantJNA(voter, 500)
+	optin, err := future.MakeApplicationOptInTx(m.mng, nil, nil, nil, nil, m.avm.SuggestedParams(), voter.Address, nil, types.Digest{}, [32]byte{}, types.Address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.send(voter, optin)
+	network := m.manager.Networks["default"]
+	network.AppID = m.mng
+	m.manager.Networks["default"] = network
+	governed, err := governedCall(m.manager, types.Address{}, "set_rate", []interface{}{uint64(7)}, m.avm.SuggestedParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := m.call(voter, m.mng, m.manager, "propose", 1, m.jna, CallHash(governed)).(uint64)
+	m.call(voter, m.mng, m.manager, "vote", 2, m.axfer(voter, crypto.GetApplicationAddress(m.mng), 500, m.jna), id, true)
+	m.avm.Advance(101)
+
+	var atc future.AtomicTransactionComposer
+	if err = atc.AddMethodCall(m.mcp(m.admin, m.mng, m.manager, "execute", 2, id)); err != nil {
+		t.Fatal(err)
+	}
+	if err = atc.AddMethodCall(m.mcp(m.admin, m.mng, m.manager, "set_rate", 0, uint64(7))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.avm.ExecuteATC(&atc); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := m.avm.Global(m.mng)["rate"].Uint; got != 7 {
+		t.Errorf("rate %d after executing the proposal, want 7", got)
+	}
+}
+
+func TestAVMMaturity(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	m.call(m.admin, m.mng, m.manager, "set_loan_terms", 1, uint64(1000), uint64(100))
+	b := m.borrower(xaid, 20, 100000)
+	liquidator := m.borrower(xaid, 0, 20000000)
+
+	m.borrow(b, l, xaid, 10, 1000000)
+	local := m.avm.Local(b.Address, m.jinaApp)
+	if xids, lmat := uint64s(stateBytes(local["xids"])), uint64s(stateBytes(local["lmat"])); len(lmat) != len(xids) {
+		t.Errorf("lmat %v does not match the slots of xids %v", lmat, xids)
+	}
+	m.repay(b, xaid, m.loan(b, "lamt"))
+	if m.loan(b, "lmat") != 0 || m.loan(b, "lrnd") != 0 {
+		t.Errorf("repaid loan kept its maturity %d or start %d", m.loan(b, "lmat"), m.loan(b, "lrnd"))
+	}
+
+	// a repaid loan past its maturity and grace is not in default
+	m.avm.Advance(2000)
+	if err := m.liquidate(liquidator, b, xaid, 0); err == nil {
+		t.Fatalf("liquidated a repaid loan")
+	}
+	if got := m.balance(b, xaid); got != 20 {
+		t.Fatalf("borrower has %d of its collateral after repaying", got)
+	}
+
+	// borrowing again in the repaid slot starts a new term
+	m.borrow(b, l, xaid, 10, 1000000)
+	lamt := m.loan(b, "lamt")
+	if err := m.liquidate(liquidator, b, xaid, lamt*11/10); err == nil {
+		t.Errorf("liquidated a new loan past the maturity of the repaid one")
+	}
+	// a top up keeps the maturity
+	m.avm.Advance(600)
+	m.borrow(b, l, xaid, 0, 1000000)
+	m.avm.Advance(600)
+	lamt = m.loan(b, "lamt")
+	if err := m.liquidate(liquidator, b, xaid, lamt*11/10); err != nil {
+		t.Errorf("could not liquidate a defaulted loan: %v", err)
+	}
+}
+
+func TestAVMRedemptionQueue(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	lender, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 1000000)
+	m.borrow(b, l, xaid, 20, 10000000)
+
+	claimant := m.account(10000000)
+	m.optinASA(claimant, m.usdc)
+	m.optinASA(claimant, m.jusd)
+	m.transfer(lender, claimant.Address, 3000000, m.jusd)
+	enqueue := func(acct crypto.Account, amt uint64) error {
+		_, _, err := m.avm.Call(m.mcp(acct, m.jinaApp, m.jina, "enqueue", 2, m.axfer(acct, crypto.GetApplicationAddress(m.jinaApp), amt, m.jusd), m.usdc, m.mng))
+		return err
+	}
+	if err := enqueue(claimant, MinRedemption-1); err == nil {
+		t.Errorf("queued less than the least redemption")
+	}
+	if err := enqueue(claimant, 3000000); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := enqueue(lender, 5000000); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	// a frozen claimant does not block the queue, repaying pays the redemption behind it
+	txn, err := future.MakeAssetFreezeTxn(m.admin.Address.String(), nil, m.avm.SuggestedParams(), m.usdc, claimant.Address.String(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.send(m.admin, txn)
+	m.repay(b, xaid, m.loan(b, "lamt"))
+	q := redemptionQueue(m.avm.Global(m.jinaApp))
+	if len(q.Redemptions) != 2 || q.Redemptions[0].Claimant != lender.Address || q.Redemptions[1].Claimant != claimant.Address {
+		t.Fatalf("frozen claimant not moved to the back of the queue %+v", q)
+	}
+	usdc := m.balance(lender, m.usdc)
+	m.call(b, m.jinaApp, m.jina, "process_queue", 2, lender.Address, m.usdc, m.mng)
+	if got := m.balance(lender, m.usdc); got != usdc+5000000 {
+		t.Errorf("lender paid %d usdc for its redemption, want 5000000", got-usdc)
+	}
+
+	txn, _ = future.MakeAssetFreezeTxn(m.admin.Address.String(), nil, m.avm.SuggestedParams(), m.usdc, claimant.Address.String(), false)
+	m.send(m.admin, txn)
+	m.call(b, m.jinaApp, m.jina, "process_queue", 2, claimant.Address, m.usdc, m.mng)
+	if got := m.balance(claimant, m.usdc); got != 3000000 {
+		t.Errorf("claimant paid %d usdc once unfrozen, want 3000000", got)
+	}
+	if q = redemptionQueue(m.avm.Global(m.jinaApp)); len(q.Redemptions) != 0 || q.Queued != 0 {
+		t.Errorf("queue not empty after paying it %+v", q)
+	}
+}
+
+func TestAVMWriteOff(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 0)
+	m.borrow(b, l, xaid, 20, 10000000)
+	liquidator := m.borrower(xaid, 0, 20000000)
+
+	// at 400000 the 20 units are worth less than the loan
+	price := uint64(400000)
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, price)
+	pay := DefaultRiskParams.WriteOffPayment(Position{Camt: 20}, price)
+	stxn := m.axfer(liquidator, crypto.GetApplicationAddress(m.lqtApp), pay, m.usdc)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddMethodCall(m.mcp(liquidator, m.lqtApp, m.lqt, "write_off", 5, stxn, b.Address, liquidator.Address, xaid, m.usdc, m.jusd, m.mng, m.jinaApp)); err != nil {
+		t.Fatal(err)
+	}
+	// jina's address receives the forwarded payment, as WriteOff adds it
+	atc, err := withAccounts(atc, 1, crypto.GetApplicationAddress(m.jinaApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.avm.ExecuteATC(&atc); err != nil {
+		t.Fatalf("write_off: %v", err)
+	}
+	if got := m.balance(liquidator, xaid); got != 20 {
+		t.Errorf("liquidator has %d of the collateral, want 20", got)
+	}
+	if got := m.loan(b, "lamt"); got != 0 {
+		t.Errorf("loan %d left after writing it off", got)
+	}
+	if bad := m.avm.Global(m.jinaApp)["bad"].Uint; bad == 0 {
+		t.Errorf("no bad debt recorded")
+	}
+
+	// lenders after the write off get JUSD at the redemption rate, not 1:1
+	rate := m.avm.Global(m.jinaApp)["rr"].Uint
+	if rate == 0 || rate >= RedemptionScale {
+		t.Fatalf("redemption rate %d after the write off", rate)
+	}
+	lender, l2 := m.lend(xaid, 100000000)
+	b2 := m.borrower(xaid, 20, 0)
+	m.borrow(b2, l2, xaid, 20, 1000000)
+	lent := 1000000 + Offer{FeeRate: DefaultFeeRate}.Fee(1000000)
+	want := BadDebt{Rate: rate}.Minted(lent)
+	if got := m.balance(lender, m.jusd); got != want || want <= lent {
+		t.Errorf("new lender sent %d JUSD for %d usdc, want %d", got, lent, want)
+	}
+}
+
+func TestAVMPartialLiquidate(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 0)
+	m.borrow(b, l, xaid, 20, 10000000)
+	liquidator := m.borrower(xaid, 0, 20000000)
+
+	// at 560000 the loan is over the threshold but still covered by the collateral
+	price := uint64(560000)
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, price)
+	p, ok := MinPartialLiquidation(Position{Camt: 20, Lamt: m.loan(b, "lamt")}, price)
+	if !ok {
+		t.Fatalf("no partial liquidation of %+v", p)
+	}
+	stxn := m.axfer(liquidator, crypto.GetApplicationAddress(m.lqtApp), p.Payment, m.usdc)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddMethodCall(m.mcp(liquidator, m.lqtApp, m.lqt, "partial_liquidate", 5, stxn, b.Address, liquidator.Address, xaid, m.usdc, m.mng, m.jinaApp)); err != nil {
+		t.Fatal(err)
+	}
+	// jina's address receives the forwarded payment, as PartialLiquidate adds it
+	atc, err := withAccounts(atc, 1, crypto.GetApplicationAddress(m.jinaApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.avm.ExecuteATC(&atc); err != nil {
+		t.Fatalf("partial_liquidate: %v", err)
+	}
+	if got := m.balance(liquidator, xaid); got != p.Units {
+		t.Errorf("liquidator has %d of the collateral, want %d", got, p.Units)
+	}
+	if got := m.loan(b, "camt"); got != 20-p.Units {
+		t.Errorf("borrower has %d collateral left, want %d", got, 20-p.Units)
+	}
+	if got := m.loan(b, "lamt"); got != p.Lamt-p.Payment {
+		t.Errorf("loan %d left, want %d", got, p.Lamt-p.Payment)
+	}
+}
+
+// bid pays amt for the collateral of b's auctioned loan, as Bid does
+func (m *market) bid(liquidator, b crypto.Account, xaid, amt uint64) error {
+	stxn := m.axfer(liquidator, crypto.GetApplicationAddress(m.lqtApp), amt, m.usdc)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddMethodCall(m.mcp(liquidator, m.lqtApp, m.lqt, "bid", 4, stxn, b.Address, liquidator.Address, xaid, m.usdc, m.mng, m.jinaApp)); err != nil {
+		return err
+	}
+	// jina's address receives the forwarded payment, as Bid adds it
+	atc, err := withAccounts(atc, 1, crypto.GetApplicationAddress(m.jinaApp))
+	if err != nil {
+		return err
+	}
+	_, err = m.avm.ExecuteATC(&atc)
+	return err
+}
+
+func TestAVMAuction(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 1000000)
+	m.borrow(b, l, xaid, 20, 10000000)
+	liquidator := m.borrower(xaid, 0, 20000000)
+
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, uint64(560000))
+	m.call(liquidator, m.lqtApp, m.lqt, "start_auction", 1, b.Address, xaid, m.mng, m.jinaApp)
+	key := auctionKey(b.Address, xaid)
+	a, ok := decodeAuction(key, stateBytes(m.avm.Global(m.lqtApp)[key]))
+	if !ok || a.LoanStart != m.loan(b, "lrnd") {
+		t.Fatalf("no auction of the loan, %+v", a)
+	}
+
+	// a repaid loan cannot be bid on, its auction is void
+	m.repay(b, xaid, m.loan(b, "lamt"))
+	if err := m.bid(liquidator, b, xaid, a.StartPrice); err == nil {
+		t.Fatalf("bid on a repaid loan")
+	}
+	if got := m.balance(b, xaid); got != 20 {
+		t.Fatalf("borrower has %d of its collateral after repaying", got)
+	}
+
+	// borrowed again, the void auction does not hold up a new one
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, uint64(1000000))
+	m.borrow(b, l, xaid, 0, 10000000) // against the collateral left in jina
+	m.call(m.admin, m.mng, m.manager, "price", 1, xaid, uint64(560000))
+	m.call(liquidator, m.lqtApp, m.lqt, "start_auction", 1, b.Address, xaid, m.mng, m.jinaApp)
+	a, _ = decodeAuction(key, stateBytes(m.avm.Global(m.lqtApp)[key]))
+	if err := m.bid(liquidator, b, xaid, a.StartPrice); err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+	if got := m.balance(liquidator, xaid); got != 20 {
+		t.Errorf("bidder has %d of the collateral, want 20", got)
+	}
+	if _, ok := m.avm.Global(m.lqtApp)[key]; ok {
+		t.Errorf("auction left after the winning bid")
+	}
+}
+
+// attest signs a price of xaid observed at round for the approval program of tealFile, usable for 1000 rounds
+func (m *market) attest(signer crypto.Account, tealFile string, xaid, price, round uint64) SignedPrice {
+	a := PriceAttestation{AssetID: xaid, Price: price, Round: round, Expiry: round + 1000}
+	sp, err := SignPrice(signer.PrivateKey, a, tealProgram(m.t, tealFile))
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return sp
+}
+
+// attestedBorrow borrows at an attested price, as Borrow does, jina making the opup calls
+func (m *market) attestedBorrow(b crypto.Account, lender LenderLsig, xaid, camt, lamt uint64, sp SignedPrice) error {
+	args := append([]interface{}{m.lenderTransfer(lender, b.Address, lamt), []uint64{xaid}, []uint64{camt}, []uint64{lamt}, lender.Lender, xaid, m.jusd, m.mng, m.lqtApp}, sp.args()...)
+	_, _, err := m.avm.Call(m.mcp(b, m.jinaApp, m.jina, "borrow", 5+attestationOpups, args...))
+	return err
+}
+
+// attestedLiquidate liquidates at an attested price, as Liquidate does
+func (m *market) attestedLiquidate(liquidator, b crypto.Account, xaid, pay uint64, sp SignedPrice) error {
+	stxn := m.axfer(liquidator, crypto.GetApplicationAddress(m.lqtApp), pay, m.usdc)
+	args := append([]interface{}{stxn, b.Address, liquidator.Address, xaid, m.usdc, m.mng, m.jinaApp}, sp.args()...)
+	mcp := m.mcp(liquidator, m.lqtApp, m.lqt, "liquidate", 4+attestationOpups, args...)
+	var atc future.AtomicTransactionComposer
+	if err := atc.AddMethodCall(mcp); err != nil {
+		return err
+	}
+	if err := addOpups(&atc, mcp, m.mng); err != nil {
+		return err
+	}
+	atc, err := withAccounts(atc, 1, crypto.GetApplicationAddress(m.jinaApp))
+	if err != nil {
+		return err
+	}
+	_, err = m.avm.ExecuteATC(&atc)
+	return err
+}
+
+func TestAVMAttestation(t *testing.T) {
+	m := deploy(t)
+	xaid := m.collateral(1000000)
+	_, l := m.lend(xaid, 100000000)
+	b := m.borrower(xaid, 20, 0)
+	signer := crypto.GenerateAccount()
+	m.call(m.admin, m.mng, m.manager, "add_signer", 1, signer.Address)
+
+	if err := m.attestedBorrow(b, l, xaid, 20, 10000000, m.attest(signer, "./teal/jinaApp.teal", xaid, 1000000, m.avm.Round)); err != nil {
+		t.Fatalf("attested borrow: %v", err)
+	}
+
+	// a low price signed now cannot be replayed once older than the max age
+	liquidator := m.borrower(xaid, 0, 20000000)
+	low := m.attest(signer, "./teal/liquidatorApp.teal", xaid, 500000, m.avm.Round)
+	high := m.attest(signer, "./teal/jinaApp.teal", xaid, 2000000, m.avm.Round)
+	m.avm.Advance(DefaultRiskParams.MaxAge + 1)
+	pay := m.loan(b, "lamt") * 110 / 100
+	if err := m.attestedLiquidate(liquidator, b, xaid, pay, low); err == nil {
+		t.Fatalf("liquidated at a replayed attestation")
+	}
+	if got := m.balance(b, xaid); got != 20 {
+		t.Errorf("borrower has %d of the collateral after the replay, want 20", got)
+	}
+	if err := m.attestedBorrow(b, l, xaid, 0, 1000000, high); err == nil {
+		t.Errorf("borrowed at a replayed attestation")
+	}
+	if err := m.attestedBorrow(b, l, xaid, 0, 1000000, m.attest(signer, "./teal/jinaApp.teal", xaid, 1000000, m.avm.Round+1)); err == nil {
+		t.Errorf("borrowed at an attestation of a round ahead")
+	}
+
+	if err := m.attestedLiquidate(liquidator, b, xaid, pay, m.attest(signer, "./teal/liquidatorApp.teal", xaid, 500000, m.avm.Round)); err != nil {
+		t.Fatalf("liquidate at a fresh attestation: %v", err)
+	}
+	if got := m.balance(liquidator, xaid); got != 20 {
+		t.Errorf("liquidator has %d of the collateral, want 20", got)
+	}
+}
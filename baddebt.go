@@ -0,0 +1,128 @@
+package jina
+
+import (
+	"context"
+	"log"
+	"math/bits"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// RedemptionScale is the denominator of the JUSD redemption rate (parts per billion of USDCa)
+const RedemptionScale = 1000000000
+
+// BadDebt reports the loans written off in a market and the loss they put on JUSD
+type BadDebt struct {
+	WrittenOff uint64 // loans left unpaid by written off positions, jina "bad"
+	Rate       uint64 // USDCa paid per JUSD, in parts per billion
+	Redeemable uint64 // JUSD outstanding or queued for redemption, as Reserves.Redeemable
+}
+
+// Value mirrors jina redemption_value: the USDCa paid for jusd at the redemption rate
+func (b BadDebt) Value(jusd uint64) uint64 {
+	return mulDiv(jusd, b.Rate, RedemptionScale)
+}
+
+// Minted mirrors jina jusd_value: the JUSD lenders are sent for usdca at the redemption rate,
+// so new deposits do not share losses written off before them
+func (b BadDebt) Minted(usdca uint64) uint64 {
+	return mulDiv(usdca, RedemptionScale, b.Rate)
+}
+
+// Shortfall is the JUSD redeemable that is no longer backed
+func (b BadDebt) Shortfall() uint64 {
+	return b.Redeemable - b.Value(b.Redeemable)
+}
+
+// WrittenOffRate mirrors jina write_off: the redemption rate after a loss is shared by the JUSD redeemable
+func WrittenOffRate(rate, redeemable, loss uint64) uint64 {
+	if redeemable == 0 {
+		return rate
+	}
+	value := mulDiv(redeemable, rate, RedemptionScale)
+	if value < loss {
+		return 0
+	}
+	return mulDiv(value-loss, RedemptionScale, redeemable)
+}
+
+// mulDiv is a*b/c as mulw and divw compute it
+func mulDiv(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	q, _ := bits.Div64(hi, lo, c)
+	return q
+}
+
+// Underwater mirrors the liquidator write off check: collateral worth less than the loan
+func (p Position) Underwater(price uint64) bool {
+	return p.Lamt > p.Value(price)
+}
+
+// WriteOffPayment is the least usdc or jusd the liquidator accepts for the collateral of an underwater loan
+func (r RiskParams) WriteOffPayment(p Position, price uint64) uint64 {
+	return mulDiv(p.Value(price), r.Reference, 100)
+}
+
+// ReadBadDebt reads the bad debt and JUSD redemption rate of a market
+func ReadBadDebt(algodClient *algod.Client, m Market) (b BadDebt, err error) {
+	state, err := globalState(algodClient, m.Jina)
+	if err != nil {
+		return
+	}
+	b.WrittenOff = state["bad"].Uint
+	b.Rate = RedemptionScale
+	if v, ok := state["rr"]; ok {
+		b.Rate = v.Uint
+	}
+	outstanding, err := jusdOutstanding(algodClient, m)
+	if err != nil {
+		return
+	}
+	b.Redeemable = Reserves{Outstanding: outstanding, Queued: state["qamt"].Uint}.Redeemable()
+	return
+}
+
+// Make liquidator application call buying the collateral of an underwater loan and writing off the rest of it
+func WriteOff(algodClient *algod.Client, acct crypto.Account, liquidatee, receiver types.Address, mng, jina, lqt, xaid, pay, jusd, amt uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	// payment, forward payment, write off loan in jina and clawback
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(5 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           lqt,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	txParams.Fee = 0
+	txn, _ := future.MakeAssetTransferTxn(acct.Address.String(), crypto.GetApplicationAddress(lqt).String(), amt, nil, txParams, "", pay)
+	stxn := future.TransactionWithSigner{Txn: txn, Signer: signer}
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "write_off"), []interface{}{stxn, liquidatee, receiver, xaid, pay, jusd, mng, jina}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+	atc, err = withAccounts(atc, 1, crypto.GetApplicationAddress(jina))
+	if err != nil {
+		return
+	}
+
+	_, err = atc.Execute(algodClient, context.Background(), 2)
+	return
+}
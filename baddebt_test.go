@@ -0,0 +1,41 @@
+package jina
+
+import "testing"
+
+func TestWrittenOffRate(t *testing.T) {
+	// 1000 JUSD redeemable at par lose 100
+	rate := WrittenOffRate(RedemptionScale, 1000, 100)
+	if rate != 900000000 {
+		t.Fatalf("rate = %d, want 900000000", rate)
+	}
+	b := BadDebt{WrittenOff: 100, Rate: rate, Redeemable: 1000}
+	if v := b.Value(500); v != 450 {
+		t.Errorf("value of 500 JUSD = %d, want 450", v)
+	}
+	if j := b.Minted(450); j != 500 {
+		t.Errorf("JUSD minted for 450 usdc = %d, want 500", j)
+	}
+	if s := b.Shortfall(); s != 100 {
+		t.Errorf("shortfall = %d, want 100", s)
+	}
+	// claims at the rate leave it unchanged for the holders left
+	if r := WrittenOffRate(rate, 500, 0); r != rate {
+		t.Errorf("rate after claims = %d, want %d", r, rate)
+	}
+	if r := WrittenOffRate(rate, 1000, 2000); r != 0 {
+		t.Errorf("rate after losing all reserves = %d", r)
+	}
+	if r := WrittenOffRate(rate, 0, 100); r != rate {
+		t.Errorf("rate without JUSD redeemable = %d", r)
+	}
+}
+
+func TestUnderwater(t *testing.T) {
+	p := Position{Camt: 10, Lamt: 1000}
+	if !p.Underwater(99) || p.Underwater(100) {
+		t.Errorf("underwater at 99 and not at 100 expected")
+	}
+	if pay := DefaultRiskParams.WriteOffPayment(p, 90); pay != 855 {
+		t.Errorf("write off payment = %d, want 855", pay)
+	}
+}
@@ -0,0 +1,165 @@
+// admin pauses and resumes borrowing and liquidation in every jina market,
+// reports and distributes the protocol fee treasury, and manages the insurance reserve
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Adg0/Jina"
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	mng := flag.Uint64("mng", 0, "manager app ID, read by status, treasury, distribute, insurance and cover")
+	usdc := flag.Uint64("usdc", 0, "stablecoin of the market for treasury, distribute, insurance and cover")
+	jna := flag.Uint64("jna", 0, "JNA asset ID for distribute")
+	indexerAddress := flag.String("indexer", "http://localhost:8980", "indexer address, lists JNA holders")
+	indexerToken := flag.String("indexer-token", strings.Repeat("a", 64), "indexer token")
+	dryRun := flag.Bool("dry-run", false, "log a distribution or cover without paying it")
+	owed := flag.String("owed", "", "JSON file of the JUSD owed to holders distribute skipped, ./owed-<usdc>.json by default")
+	share := flag.Uint64("share", 0, "insurance share of lender fees in basis points for set-insurance")
+	amount := flag.Uint64("amount", 0, "reserve JUSD sent by cover, the whole pending shortfall when zero")
+	contract := flag.String("contract", "./abi/manager.json", "manager ABI file")
+	reason := flag.String("reason", "", "reason recorded in the transaction note")
+	account := flag.Int("account", 0, "sandbox account index, used when ADMIN_MNEMONIC is unset")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: admin [flags] pause|unpause|status|treasury|distribute|insurance|set-insurance|cover\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+	acct, err := jina.LoadAccount("ADMIN_MNEMONIC", *account)
+	if err != nil {
+		log.Fatalf("Failed to load account: %s", err)
+	}
+
+	switch flag.Arg(0) {
+	case "pause":
+		err = jina.Pause(algodClient, acct, *reason, *contract)
+	case "unpause":
+		err = jina.Unpause(algodClient, acct, *reason, *contract)
+	case "status":
+		var paused bool
+		paused, err = jina.Paused(algodClient, *mng)
+		if err == nil {
+			fmt.Printf("manager %d paused: %t\n", *mng, paused)
+		}
+	case "treasury", "distribute":
+		if *owed == "" {
+			*owed = fmt.Sprintf("./owed-%d.json", *usdc)
+		}
+		err = treasury(algodClient, acct, *mng, *usdc, *jna, *indexerAddress, *indexerToken, *node, *owed, *contract, flag.Arg(0) == "distribute" && !*dryRun)
+	case "set-insurance":
+		err = jina.SetInsuranceShare(algodClient, acct, *share, *contract)
+	case "insurance", "cover":
+		err = insurance(algodClient, acct, *mng, *usdc, *amount, *contract, flag.Arg(0) == "cover" && !*dryRun)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s found error: %s", flag.Arg(0), err)
+	}
+}
+
+// treasury reports the protocol fees of a market and pays them out pro rata to JNA holders when pay is set,
+// keeping what skipped holders are owed in owedFile
+func treasury(algodClient *algod.Client, acct crypto.Account, mng, usdc, jna uint64, indexerAddress, indexerToken, node, owedFile, contract string, pay bool) error {
+	markets, err := jina.Markets(algodClient, mng)
+	if err != nil {
+		return err
+	}
+	m, err := jina.FindMarket(markets, usdc)
+	if err != nil {
+		return err
+	}
+	t, err := jina.ReadTreasury(algodClient, mng, m)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("market %d: received %d, paid %d, balance %d jusd\n", m.Stablecoin, t.Received, t.Paid, t.Balance())
+	if jna == 0 || t.Balance() == 0 {
+		return nil
+	}
+	indexerClient, err := jina.InitIndexerClient(indexerAddress, indexerToken, node)
+	if err != nil {
+		return err
+	}
+	holders, err := jina.JNAHolders(indexerClient, mng, jna)
+	if err != nil {
+		return err
+	}
+	owed, err := jina.ReadOwed(owedFile)
+	if err != nil {
+		return err
+	}
+	payouts, skipped, owed, err := jina.Distribution(holders, t.Balance(), owed, func(addr types.Address) (bool, error) {
+		return jina.HoldsAsset(algodClient, addr, m.IOU)
+	})
+	if err != nil {
+		return err
+	}
+	for _, addr := range skipped {
+		log.Printf("%s has not opted in to jusd %d, owed %d for a later distribution", addr, m.IOU, owed[addr])
+	}
+	for _, p := range payouts {
+		fmt.Printf("%s %d\n", p.Address, p.Amount)
+	}
+	if !pay {
+		return nil
+	}
+	if err = jina.Distribute(algodClient, acct, m, payouts, contract); err != nil {
+		return err
+	}
+	return jina.WriteOwed(owedFile, owed)
+}
+
+// insurance reports the insurance reserve and bad debt of a market and covers its pending shortfall when pay is set
+func insurance(algodClient *algod.Client, acct crypto.Account, mng, usdc, amount uint64, contract string, pay bool) error {
+	markets, err := jina.Markets(algodClient, mng)
+	if err != nil {
+		return err
+	}
+	m, err := jina.FindMarket(markets, usdc)
+	if err != nil {
+		return err
+	}
+	i, err := jina.ReadInsurance(algodClient, mng, m)
+	if err != nil {
+		return err
+	}
+	b, err := jina.ReadBadDebt(algodClient, m)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("market %d: share %d bp, reserve %d jusd (received %d, spent %d), shortfall %d\n", m.Stablecoin, i.Share, i.Reserve(), i.Received, i.Spent, i.Shortfall)
+	fmt.Printf("bad debt %d, redemption rate %d/%d, unbacked %d of %d jusd\n", b.WrittenOff, b.Rate, jina.RedemptionScale, b.Shortfall(), b.Redeemable)
+	if amount == 0 {
+		amount = i.CoverAmount(b.Rate)
+	}
+	if amount == 0 {
+		return nil
+	}
+	fmt.Printf("cover %d jusd\n", amount)
+	if !pay {
+		return nil
+	}
+	return jina.Cover(algodClient, acct, m, amount, contract)
+}
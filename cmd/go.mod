@@ -2,7 +2,10 @@ module github.com/Adg0/Jina/cmd
 
 go 1.17
 
-require github.com/algorand/go-algorand-sdk v1.14.1
+require (
+	github.com/Adg0/Jina v0.0.0
+	github.com/algorand/go-algorand-sdk v1.14.1
+)
 
 require (
 	github.com/algorand/go-algorand v0.0.0-20220323144801-17c0feef002f // indirect
@@ -10,3 +13,5 @@ require (
 	github.com/google/go-querystring v1.0.0 // indirect
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
 )
+
+replace github.com/Adg0/Jina => ../
@@ -0,0 +1,125 @@
+// gov creates, votes on and executes JNA governance proposals of the manager app
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Adg0/Jina"
+)
+
+const usage = `usage: gov [flags] command
+commands:
+  list                        open proposals
+  optin                       opt in to the manager to vote
+  propose <method> [args...]  propose a manager call
+  vote <id> yes|no            lock -amount JNA on a proposal
+  unlock                      return JNA locked in a vote
+  execute <id> <method> [args...]
+  close <id>
+  enable                      hand parameter changes and upgrades over to proposals
+`
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	mng := flag.Uint64("mng", 0, "manager app ID")
+	jna := flag.Uint64("jna", 0, "JNA asset ID")
+	contract := flag.String("contract", "./abi/manager.json", "manager ABI file")
+	amount := flag.Uint64("amount", 0, "JNA to vote with")
+	fees := flag.Uint64("fees", 1, "inner transactions of the executed call to pay for")
+	account := flag.Int("account", 0, "sandbox account index, used when GOV_MNEMONIC is unset")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+	acct, err := jina.LoadAccount("GOV_MNEMONIC", *account)
+	if err != nil {
+		log.Fatalf("Failed to load account: %s", err)
+	}
+
+	id := func() uint64 {
+		if len(args) < 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("Bad proposal ID: %s", err)
+		}
+		return id
+	}
+	call := func(from int) (string, []interface{}) {
+		if len(args) <= from {
+			flag.Usage()
+			os.Exit(2)
+		}
+		values, err := jina.ParseMethodArgs(args[from], args[from+1:], *contract)
+		if err != nil {
+			log.Fatalf("Bad call: %s", err)
+		}
+		return args[from], values
+	}
+
+	switch args[0] {
+	case "list":
+		var proposals []jina.Proposal
+		var g jina.Governance
+		proposals, g, err = jina.Proposals(algodClient, *mng)
+		if err != nil {
+			break
+		}
+		fmt.Printf("quorum %d JNA, voting window %d rounds, enabled %t\n", g.Quorum, g.Window, g.Enabled)
+		for _, p := range proposals {
+			fmt.Printf("%d hash %x ends %d yes %d no %d passing %t\n", p.ID, p.Hash, p.End, p.Yes, p.No, p.Passed(g.Quorum))
+		}
+	case "optin":
+		err = jina.GovernanceOptIn(algodClient, acct, *mng)
+	case "propose":
+		method, values := call(1)
+		var pid uint64
+		pid, err = jina.Propose(algodClient, acct, *jna, method, values, *contract)
+		if err == nil {
+			fmt.Printf("proposal %d\n", pid)
+		}
+	case "vote":
+		pid := id()
+		if len(args) != 3 || (args[2] != "yes" && args[2] != "no") {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = jina.Vote(algodClient, acct, *jna, pid, *amount, args[2] == "yes", *contract)
+	case "unlock":
+		err = jina.Unlock(algodClient, acct, *jna, *contract)
+	case "execute":
+		pid := id()
+		method, values := call(2)
+		err = jina.ExecuteProposal(algodClient, acct, pid, method, values, *fees, *contract)
+	case "close":
+		err = jina.CloseProposal(algodClient, acct, id(), *contract)
+	case "enable":
+		err = jina.EnableGovernance(algodClient, acct, *contract)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s found error: %s", args[0], err)
+	}
+}
@@ -0,0 +1,74 @@
+// keeper follows jina positions and liquidates loans above the liquidator's 90% threshold
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/Adg0/Jina"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	mng := flag.Uint64("mng", 0, "manager app ID")
+	jinaApp := flag.Uint64("jina", 0, "jina app ID")
+	lqt := flag.Uint64("lqt", 0, "liquidator app ID")
+	pay := flag.Uint64("pay", 0, "usdc or jusd asset ID paid to liquidate")
+	contract := flag.String("contract", "./abi/lqt.json", "liquidator ABI file")
+	receiver := flag.String("receiver", "", "account receiving liquidated collateral, defaults to the keeper")
+	borrowers := flag.String("borrowers", "", "comma separated borrowers to track from the start")
+	start := flag.Uint64("start", 0, "first round to scan for borrowers, defaults to the current round")
+	minProfit := flag.Uint64("min-profit", 1000000, "least profit in usdc micro units to liquidate")
+	account := flag.Int("account", 0, "sandbox account index, used when KEEPER_MNEMONIC is unset")
+	dryRun := flag.Bool("dry-run", false, "log liquidations without submitting them")
+	flag.Parse()
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+	acct, err := jina.LoadAccount("KEEPER_MNEMONIC", *account)
+	if err != nil {
+		log.Fatalf("Failed to load account: %s", err)
+	}
+	cfg := jina.KeeperConfig{
+		Mng:         *mng,
+		Jina:        *jinaApp,
+		Lqt:         *lqt,
+		Pay:         *pay,
+		MinProfit:   *minProfit,
+		StartRound:  *start,
+		DryRun:      *dryRun,
+		LqtContract: *contract,
+	}
+	if *receiver != "" {
+		cfg.Receiver, err = types.DecodeAddress(*receiver)
+		if err != nil {
+			log.Fatalf("Bad receiver: %s", err)
+		}
+	}
+	for _, b := range strings.Split(*borrowers, ",") {
+		if b == "" {
+			continue
+		}
+		addr, err := types.DecodeAddress(b)
+		if err != nil {
+			log.Fatalf("Bad borrower %q: %s", b, err)
+		}
+		cfg.Borrowers = append(cfg.Borrowers, addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = jina.NewKeeper(algodClient, acct, cfg).Run(ctx)
+	if err != nil && err != context.Canceled {
+		log.Fatalf("keeper stopped: %s", err)
+	}
+}
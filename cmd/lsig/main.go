@@ -0,0 +1,97 @@
+// lsig inspects a lender's delegated lsig codec file before borrowing against it
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Adg0/Jina"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	codec := flag.String("codec", "./codec/lender_lsig.codec", "lsig codec file")
+	lender := flag.String("lender", "", "claimed lender address, the lsig signing key when empty")
+	teal := flag.String("teal", "./teal/logicSigDelegated.teal", "lender lsig template")
+	usdc := flag.Uint64("usdc", 0, "stablecoin the lsig should lend")
+	jinaApp := flag.Uint64("jina", 0, "jina app of the market, the offer id is read from the lender's offer when set")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: lsig [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+	lsa, err := jina.ReadLsigFile(*codec)
+	if err != nil {
+		log.Fatalf("Failed to read lsig: %s", err)
+	}
+
+	var addr types.Address
+	if *lender != "" {
+		addr, err = types.DecodeAddress(*lender)
+		if err != nil {
+			log.Fatalf("Failed to decode lender: %s", err)
+		}
+	} else if len(lsa.SigningKey) == ed25519.PublicKeySize {
+		copy(addr[:], lsa.SigningKey)
+		log.Printf("no -lender, checking against the lsig signing key %s", addr)
+	} else {
+		log.Fatalf("no -lender and the lsig has no signing key")
+	}
+
+	// the terms and offer id are baked into the program, rebuilt from the terms the unsigned args claim
+	// with USDCa, jina and the offer id taken from the flags and the lender's offer when set
+	terms, _ := jina.DecodeLenderArgs(lsa.Lsig.Args)
+	t := terms.Template(addr)
+	if *usdc != 0 {
+		t.USDCa = *usdc
+	}
+	if *jinaApp != 0 {
+		t.Jina = *jinaApp
+		offers, err := jina.Offers(algodClient, *jinaApp, []types.Address{addr})
+		if err != nil || len(offers) == 0 || len(offers[0].Lsa) != 32 {
+			log.Fatalf("%s has no offer in jina %d: %v", addr, *jinaApp, err)
+		}
+		copy(t.Offer[:], offers[0].Lsa)
+	}
+	program, err := jina.ExpectedLenderProgram(algodClient, *teal, t)
+	if err != nil {
+		log.Fatalf("Failed to compile %s: %s", *teal, err)
+	}
+	status, err := algodClient.Status().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get status: %s", err)
+	}
+
+	r := jina.InspectLenderLsig(lsa, addr, program, *usdc, *jinaApp, status.LastRound)
+	fmt.Printf("lender     %s\n", r.Lender)
+	fmt.Printf("program    %s (matches template: %t)\n", r.ProgramHash, r.ProgramMatches)
+	fmt.Printf("signature  valid: %t\n", r.SignatureValid)
+	if r.ProgramMatches {
+		fmt.Printf("terms      baked into the program\n")
+	} else {
+		fmt.Printf("terms      UNVERIFIED, read from the unsigned lsig args\n")
+	}
+	fmt.Printf("asset      %d\n", r.Terms.USDCa)
+	fmt.Printf("max amount %d\n", r.Terms.Amount)
+	fmt.Printf("expires    round %d (now %d)\n", r.Terms.LastValid, status.LastRound)
+	fmt.Printf("jina app   %d\n", r.Terms.Jina)
+	for _, w := range r.Warnings {
+		fmt.Printf("UNSAFE: %s\n", w)
+	}
+	if !r.Safe() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+// lsigd serves lender lsigs to borrowers, verified against the lenders' offers in a jina app
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Adg0/Jina"
+)
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	listen := flag.String("listen", ":8080", "address to serve on")
+	usdc := flag.Uint64("usdc", 0, "stablecoin of the market, any when zero")
+	jinaApp := flag.Uint64("jina", 0, "jina app of the market")
+	teal := flag.String("teal", "./teal/logicSigDelegated.teal", "lender lsig template")
+	dir := flag.String("dir", "./lsigs", "directory keeping uploaded lsigs, none when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: lsigd -jina <app> [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *jinaApp == 0 {
+		flag.Usage()
+		log.Fatalf("no -jina")
+	}
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+	registry, err := jina.NewLsigRegistry(algodClient, *usdc, *jinaApp, *teal, *dir)
+	if err != nil {
+		log.Fatalf("Failed to load lsigs: %s", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/lsigs", registry)
+	mux.Handle("/lsigs/", registry)
+	log.Printf("serving %d lsigs of jina %d on %s", len(registry.List()), *jinaApp, *listen)
+	log.Fatal(http.ListenAndServe(*listen, mux))
+}
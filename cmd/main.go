@@ -4,7 +4,7 @@ import (
 	"log"
 	"strings"
 
-	"github.com/Adg0/Jina"
+	Jina "github.com/Adg0/Jina"
 )
 
 var (
@@ -69,7 +69,6 @@ func main() {
 	lqt := ids[0]
 	jina := ids[1]
 	jusd := ids[2]
-	jna := ids[3]
 	err = Jina.ConfigureApps(algodClient, accts[0], lqt, jina, usdc, jusd, "./abi/manager.json")
 	if err != nil {
 		log.Fatalf("Configuring created apps found error: %s", err)
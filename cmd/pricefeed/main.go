@@ -0,0 +1,80 @@
+// pricefeed polls NFT price sources and publishes aggregated prices to the manager app
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Adg0/Jina"
+)
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	mng := flag.Uint64("mng", 0, "manager app ID")
+	contract := flag.String("contract", "./abi/manager.json", "manager ABI file")
+	sourcesFile := flag.String("sources", "./pricefeed.json", "JSON list of price sources")
+	assets := flag.String("assets", "", "comma separated collateral asset IDs")
+	account := flag.Int("account", 0, "sandbox account index, used when PRICEFEED_MNEMONIC is unset")
+	interval := flag.Duration("interval", time.Minute, "time between polls")
+	maxAge := flag.Duration("max-age", 10*time.Minute, "quotes older than this are stale")
+	minSources := flag.Int("min-sources", 1, "fresh quotes needed to aggregate")
+	outlier := flag.Uint64("outlier-bps", 1500, "reject quotes further than this from the median")
+	maxDev := flag.Uint64("max-deviation-bps", 3000, "clamp moves larger than this per poll")
+	threshold := flag.Uint64("threshold-bps", 100, "publish only moves of at least this")
+	once := flag.Bool("once", false, "poll once and exit")
+	flag.Parse()
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+	acct, err := jina.LoadAccount("PRICEFEED_MNEMONIC", *account)
+	if err != nil {
+		log.Fatalf("Failed to load account: %s", err)
+	}
+	sources, err := jina.LoadPriceSources(*sourcesFile)
+	if err != nil {
+		log.Fatalf("Failed to load price sources: %s", err)
+	}
+	cfg := jina.FeedConfig{
+		Interval:     *interval,
+		MaxAge:       *maxAge,
+		MinSources:   *minSources,
+		OutlierBps:   *outlier,
+		MaxDeviation: *maxDev,
+		Threshold:    *threshold,
+	}
+	for _, a := range strings.Split(*assets, ",") {
+		if a == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(a, 10, 64)
+		if err != nil {
+			log.Fatalf("Bad asset ID %q: %s", a, err)
+		}
+		cfg.Assets = append(cfg.Assets, id)
+	}
+	if len(cfg.Assets) == 0 {
+		log.Fatalf("No assets to price, set -assets")
+	}
+	if *mng == 0 {
+		log.Fatalf("No manager app, set -mng")
+	}
+
+	feed := jina.NewPriceFeed(algodClient, acct, *mng, *contract, cfg, sources...)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *once {
+		feed.Poll(ctx)
+		return
+	}
+	feed.Run(ctx)
+}
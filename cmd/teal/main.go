@@ -0,0 +1,112 @@
+// teal assembles TEAL files offline, printing size and program hash,
+// and with -check compares the bytecode with algod's. Build with -tags offline
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Adg0/Jina"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// params collects -param NAME=value, bytes as 0x hex, addresses or ints
+type params jina.TemplateParams
+
+func (p params) String() string { return "" }
+
+func (p params) Set(s string) error {
+	name, value := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		name, value = s[:i], s[i+1:]
+	}
+	name = strings.TrimPrefix(name, "TMPL_")
+	if strings.HasPrefix(value, "0x") {
+		b, err := hex.DecodeString(value[2:])
+		p[name] = jina.TmplBytes(b)
+		return err
+	}
+	if addr, err := types.DecodeAddress(value); err == nil {
+		p[name] = jina.TmplAddr(addr)
+		return nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	p[name] = jina.TmplInt(v)
+	return err
+}
+
+// used are the params with a placeholder in tealFile, so one set serves several files
+func (p params) used(tealFile []byte) jina.TemplateParams {
+	u := jina.TemplateParams{}
+	for name, v := range p {
+		if regexp.MustCompile(`TMPL_` + regexp.QuoteMeta(name) + `\b`).Match(tealFile) {
+			u[name] = v
+		}
+	}
+	return u
+}
+
+func main() {
+	algodAddress := flag.String("algod", "http://localhost:4001", "algod address")
+	algodToken := flag.String("token", strings.Repeat("a", 64), "algod token")
+	node := flag.String("node", "local", "local or purestake")
+	check := flag.Bool("check", false, "compare with algod compile")
+	p := params{}
+	flag.Var(p, "param", "TMPL_ value NAME=value, repeatable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: teal [flags] file.teal...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	algodClient, err := jina.InitAlgodClient(*algodAddress, *algodToken, *node)
+	if err != nil {
+		log.Fatalf("algodClient found error: %s", err)
+	}
+
+	failed := false
+	for _, f := range flag.Args() {
+		tealFile, err := ioutil.ReadFile(f)
+		if err == nil {
+			tealFile, err = p.used(tealFile).Substitute(tealFile)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", f, err)
+			failed = true
+			continue
+		}
+		if !*check {
+			program, err := jina.AssembleOffline(tealFile)
+			if err != nil {
+				fmt.Printf("%s: %v\n", f, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s %d bytes %s\n", f, len(program), crypto.AddressFromProgram(program))
+			continue
+		}
+		c, err := jina.CheckAssembly(algodClient, tealFile)
+		if err != nil {
+			fmt.Printf("%s: %v\n", f, err)
+			failed = true
+			continue
+		}
+		if !c.Match() {
+			fmt.Printf("%s MISMATCH offline %d bytes %s, algod %d bytes %s\n", f, len(c.Offline), c.OfflineHash, len(c.Algod), c.AlgodHash)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s %d bytes %s matches algod\n", f, len(c.Offline), c.OfflineHash)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
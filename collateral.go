@@ -0,0 +1,106 @@
+package jina
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+// Oracle sources a registered collateral accepts
+const (
+	OracleAny      = 0
+	OracleOnChain  = 1 // prices published to the manager
+	OracleAttested = 2 // signed price attestations
+)
+
+// Collateral is an entry of the manager collateral registry
+type Collateral struct {
+	AssetID uint64
+	MaxLTV  uint64 // percent of collateral value lent at most, up to the liquidation threshold
+	Haircut uint64 // basis points taken off the oracle price
+	Source  uint64 // OracleAny, OracleOnChain or OracleAttested
+}
+
+// Price is the oracle price less the haircut, as borrow and liquidation value collateral
+func (c Collateral) Price(oracle uint64) uint64 {
+	return oracle * (10000 - c.Haircut) / 10000
+}
+
+// Validate mirrors the checks of set_collateral against the manager's liquidation threshold
+func (c Collateral) Validate(threshold uint64) error {
+	if c.MaxLTV > threshold {
+		return fmt.Errorf("max ltv %d%% above the %d%% liquidation threshold", c.MaxLTV, threshold)
+	}
+	if c.Haircut >= 10000 {
+		return fmt.Errorf("haircut %d bps takes the whole price", c.Haircut)
+	}
+	if c.Source > OracleAttested {
+		return fmt.Errorf("unknown oracle source %d", c.Source)
+	}
+	return nil
+}
+
+// registry key "x"||xaid, value max ltv||haircut||oracle source
+func collateralKey(assetID uint64) string {
+	key := make([]byte, 9)
+	key[0] = 'x'
+	binary.BigEndian.PutUint64(key[1:], assetID)
+	return string(key)
+}
+
+func decodeCollateral(key string, value []byte) (c Collateral, ok bool) {
+	if len(key) != 9 || key[0] != 'x' || len(value) != 24 {
+		return
+	}
+	c.AssetID = binary.BigEndian.Uint64([]byte(key[1:]))
+	c.MaxLTV = binary.BigEndian.Uint64(value[0:8])
+	c.Haircut = binary.BigEndian.Uint64(value[8:16])
+	c.Source = binary.BigEndian.Uint64(value[16:24])
+	return c, true
+}
+
+// CollateralRegistry lists the approved collateral of the manager app by asset ID
+func CollateralRegistry(algodClient *algod.Client, mng uint64) (registry []Collateral, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	for k, v := range state {
+		if c, ok := decodeCollateral(k, stateBytes(v)); ok {
+			registry = append(registry, c)
+		}
+	}
+	sort.Slice(registry, func(i, j int) bool { return registry[i].AssetID < registry[j].AssetID })
+	return
+}
+
+// ReadCollateral reads the registry entry of an asset, ok is false when it is not approved
+func ReadCollateral(algodClient *algod.Client, mng, assetID uint64) (c Collateral, ok bool, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	key := collateralKey(assetID)
+	c, ok = decodeCollateral(key, stateBytes(state[key]))
+	return
+}
+
+// Make application call to manager mng approving a collateral asset or updating its parameters
+func SetCollateral(algodClient *algod.Client, acct crypto.Account, mng uint64, c Collateral, contract_json string) (err error) {
+	r, err := ReadRiskParams(algodClient, mng)
+	if err != nil {
+		return
+	}
+	if err = c.Validate(r.Threshold); err != nil {
+		return
+	}
+	return callAppMethod(algodClient, acct, mng, "set_collateral", []interface{}{c.AssetID, c.MaxLTV, c.Haircut, c.Source}, contract_json)
+}
+
+// Make application call to manager mng removing a collateral asset from the registry
+func RemoveCollateral(algodClient *algod.Client, acct crypto.Account, mng, assetID uint64, contract_json string) (err error) {
+	return callAppMethod(algodClient, acct, mng, "remove_collateral", []interface{}{assetID}, contract_json)
+}
@@ -0,0 +1,35 @@
+package jina
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeCollateral(t *testing.T) {
+	value := make([]byte, 24)
+	binary.BigEndian.PutUint64(value[0:], 70)
+	binary.BigEndian.PutUint64(value[8:], 500)
+	binary.BigEndian.PutUint64(value[16:], OracleAttested)
+
+	c, ok := decodeCollateral(collateralKey(2), value)
+	if !ok || c.AssetID != 2 || c.MaxLTV != 70 || c.Haircut != 500 || c.Source != OracleAttested {
+		t.Fatalf("wrong collateral %+v", c)
+	}
+	if got := c.Price(1000); got != 950 {
+		t.Errorf("haircut price = %d", got)
+	}
+	if _, ok = decodeCollateral(collateralKey(2)[:8], value); ok {
+		t.Errorf("decoded collateral from unrelated key")
+	}
+}
+
+func TestCollateralValidate(t *testing.T) {
+	for _, c := range []Collateral{{MaxLTV: 81}, {Haircut: 10000}, {Source: 3}} {
+		if c.Validate(80) == nil {
+			t.Errorf("invalid collateral %+v accepted", c)
+		}
+	}
+	if err := (Collateral{MaxLTV: 80, Haircut: 9999, Source: OracleOnChain}).Validate(80); err != nil {
+		t.Errorf("valid collateral rejected: %v", err)
+	}
+}
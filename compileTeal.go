@@ -1,45 +1,47 @@
 package jina
 
 import (
-	"context"
 	"crypto/ed25519"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"os"
 
 	"github.com/algorand/go-algorand-sdk/client/v2/algod"
 	"github.com/algorand/go-algorand-sdk/crypto"
 )
 
 func CompileToLsig(algodClient *algod.Client, args [][]byte, osTealFile, codecFile string, sk ed25519.PrivateKey) (lsa crypto.LogicSigAccount) {
+	return CompileTemplateLsig(algodClient, args, nil, osTealFile, codecFile, sk)
+}
 
+// CompileTemplateLsig substitutes the TMPL_ placeholders of a TEAL file before compiling and signing it
+func CompileTemplateLsig(algodClient *algod.Client, args [][]byte, params TemplateParams, osTealFile, codecFile string, sk ed25519.PrivateKey) (lsa crypto.LogicSigAccount) {
 	// the Teal program to compile
-	file, err := os.Open(osTealFile)
+	tealFile, err := ReadTemplate(osTealFile, params)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
-	tealFile, err := ioutil.ReadAll(file)
-	if err != nil {
-		fmt.Printf("failed to read teal file: %s\n", err)
-		return
-	}
+	return compileLsig(algodClient, args, tealFile, codecFile, sk)
+}
 
-	// compile teal program
-	response, err := algodClient.TealCompile(tealFile).Do(context.Background())
-	// print response
-	fmt.Printf("Hash = %s\n", response.Hash)
-	fmt.Printf("Result = %s\n", response.Result)
+// CompileLenderLsig compiles the lender's delegated lsig bound to the offer id passed to earn
+func CompileLenderLsig(algodClient *algod.Client, args [][]byte, t LenderTemplate, osTealFile, codecFile string, sk ed25519.PrivateKey) crypto.LogicSigAccount {
+	return CompileTemplateLsig(algodClient, args, t.Params(), osTealFile, codecFile, sk)
+}
+
+// CompileDispenserLsig compiles the dispenser's delegated lsig for its asset and cap
+func CompileDispenserLsig(algodClient *algod.Client, t DispenserTemplate, osTealFile, codecFile string, sk ed25519.PrivateKey) crypto.LogicSigAccount {
+	return CompileTemplateLsig(algodClient, nil, t.Params(), osTealFile, codecFile, sk)
+}
 
-	program, err := base64.StdEncoding.DecodeString(response.Result)
+func compileLsig(algodClient *algod.Client, args [][]byte, tealFile []byte, codecFile string, sk ed25519.PrivateKey) (lsa crypto.LogicSigAccount) {
+	// compile teal program
+	program, err := compileProgram(algodClient, tealFile)
 	if err != nil {
-		fmt.Printf("Error decoding program: %s\n", err)
+		fmt.Printf("Error compiling program: %s\n", err)
 		return
 	}
-
 	// Signing the TEAL
 	lsa, err = crypto.MakeLogicSigAccountDelegated(program, args, sk)
 	if err != nil {
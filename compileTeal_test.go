@@ -1,3 +1,9 @@
+//go:build sandbox
+// +build sandbox
+
+// Integration tests against a local sandbox node, run with -tags sandbox.
+// Its accounts and ids (AlgodAddressSandbox, ToMn, USDCa, AppID, ...) are kept out of the repo in an untracked file with the same tag
+
 package jina
 
 import (
@@ -38,7 +44,7 @@ func TestCompileToLsig(t *testing.T) {
 	lsigArgs[2] = buf[2][:]
 	lsigArgs[3] = buf[3][:]
 
-	lsa := CompileToLsig(algodClient, lsigArgs, "./teal/logicSigDelegated.teal", "./codec/lender_lsig_To.codec", sk)
+	lsa := CompileLenderLsig(algodClient, lsigArgs, LenderTemplate{USDCa: USDCa, Amount: 50000000, LastValid: 172800 + uint64(txParams.FirstRoundValid), Jina: AppID}, "./teal/logicSigDelegated.teal", "./codec/lender_lsig_To.codec", sk)
 	if lsa.SigningKey == nil {
 		t.Errorf("lsig is empty")
 	}
@@ -56,14 +62,8 @@ func TestCompileToLsigDispenser(t *testing.T) {
 		return
 	}
 
-	lsigArgs := make([][]byte, 2)
-	var buf [2][8]byte
-	binary.BigEndian.PutUint64(buf[0][:], LFT_jina) // JUSD asset ID
-	binary.BigEndian.PutUint64(buf[1][:], 4)        // maximum one time dispense
-	lsigArgs[0] = buf[0][:]
-	lsigArgs[1] = buf[1][:]
-
-	lsa := CompileToLsig(algodClient, lsigArgs, "./teal/dispense.teal", "./codec/dispenserLFT.codec", sk)
+	// JUSD asset ID, maximum one time dispense
+	lsa := CompileDispenserLsig(algodClient, DispenserTemplate{Asset: LFT_jina, Cap: 4}, "./teal/dispense.teal", "./codec/dispenserLFT.codec", sk)
 	if lsa.SigningKey == nil {
 		t.Errorf("lsig is empty")
 	}
@@ -1,3 +1,9 @@
+//go:build sandbox
+// +build sandbox
+
+// Integration tests against a local sandbox node, run with -tags sandbox.
+// Its accounts and ids (AlgodAddressSandbox, ReserveAddr, JUSD, ...) are kept out of the repo in an untracked file with the same tag
+
 package jina
 
 import (
@@ -0,0 +1,374 @@
+package jina
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/algorand/go-algorand-sdk/abi"
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// Proposal is a manager call JNA holders vote on, keyed "g"||id in manager global state
+type Proposal struct {
+	ID   uint64
+	Hash [32]byte // CallHash of the governed call
+	End  uint64   // last round of voting
+	Yes  uint64   // JNA locked in favour
+	No   uint64   // JNA locked against
+}
+
+// Governance is the quorum and voting window of proposals
+type Governance struct {
+	Quorum  uint64 // JNA that must vote
+	Window  uint64 // rounds of voting, and of execution after it
+	Enabled bool   // parameter changes and upgrades need an executed proposal
+}
+
+// Passed mirrors the manager execute check once voting ended
+func (p Proposal) Passed(quorum uint64) bool {
+	return p.Yes > p.No && p.Yes+p.No >= quorum
+}
+
+// CallHash mirrors the manager call_hash subroutine: sha256 of OnCompletion, hashes of args,
+// foreign assets, apps and accounts, and hashes of the programs
+func CallHash(txn types.Transaction) [32]byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(txn.OnCompletion))
+	for _, arg := range txn.ApplicationArgs {
+		h := sha256.Sum256(arg)
+		b = append(b, h[:]...)
+	}
+	for _, id := range txn.ForeignAssets {
+		b = appendUint64(b, uint64(id))
+	}
+	for _, id := range txn.ForeignApps {
+		b = appendUint64(b, uint64(id))
+	}
+	for _, addr := range txn.Accounts {
+		b = append(b, addr[:]...)
+	}
+	approval := sha256.Sum256(txn.ApprovalProgram)
+	clear := sha256.Sum256(txn.ClearStateProgram)
+	b = append(append(b, approval[:]...), clear[:]...)
+	return sha256.Sum256(b)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var u [8]byte
+	binary.BigEndian.PutUint64(u[:], v)
+	return append(b, u[:]...)
+}
+
+func proposalKey(id uint64) string {
+	b := make([]byte, 9)
+	b[0] = 'g'
+	binary.BigEndian.PutUint64(b[1:], id)
+	return string(b)
+}
+
+func decodeProposal(key string, value []byte) (p Proposal, ok bool) {
+	if len(key) != 9 || key[0] != 'g' || len(value) != 56 {
+		return
+	}
+	p.ID = binary.BigEndian.Uint64([]byte(key[1:]))
+	copy(p.Hash[:], value)
+	p.End = binary.BigEndian.Uint64(value[32:])
+	p.Yes = binary.BigEndian.Uint64(value[40:])
+	p.No = binary.BigEndian.Uint64(value[48:])
+	return p, true
+}
+
+// Proposals lists the open proposals of the manager app and its governance parameters
+func Proposals(algodClient *algod.Client, mng uint64) (proposals []Proposal, g Governance, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	for k, v := range state {
+		if p, ok := decodeProposal(k, stateBytes(v)); ok {
+			proposals = append(proposals, p)
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].ID < proposals[j].ID })
+	g = Governance{Quorum: state["gq"].Uint, Window: state["gwin"].Uint, Enabled: state["gov"].Uint != 0}
+	return
+}
+
+// governedCall builds the manager method call a proposal governs, unsigned
+func governedCall(contract *abi.Contract, sender types.Address, method string, args []interface{}, txParams types.SuggestedParams) (txn types.Transaction, err error) {
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(future.AddMethodCallParams{
+		AppID:           contract.Networks["default"].AppID,
+		Method:          getMethod(contract, method),
+		MethodArgs:      args,
+		Sender:          sender,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          future.BasicAccountTransactionSigner{},
+	})
+	if err != nil {
+		return
+	}
+	group, err := atc.BuildGroup()
+	if err != nil {
+		return
+	}
+	return group[0].Txn, nil
+}
+
+// ParseMethodArgs converts command line arguments of a manager method to ABI values.
+// Integers, assets and applications are decimal, accounts are addresses,
+// and byte[] is read from a file when prefixed with @.
+func ParseMethodArgs(method string, args []string, contract_json string) (values []interface{}, err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+	m := getMethod(contract, method)
+	if len(args) != len(m.Args) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", method, len(m.Args), len(args))
+	}
+	for i, a := range m.Args {
+		var v interface{}
+		switch a.Type {
+		case "uint64", "asset", "application":
+			v, err = strconv.ParseUint(args[i], 10, 64)
+		case "address", "account":
+			v, err = types.DecodeAddress(args[i])
+		case "bool":
+			v, err = strconv.ParseBool(args[i])
+		case "byte[]":
+			if strings.HasPrefix(args[i], "@") {
+				v, err = os.ReadFile(args[i][1:])
+			} else {
+				v = []byte(args[i])
+			}
+		default:
+			err = fmt.Errorf("unsupported argument type %s", a.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s argument %s: %v", method, a.Name, err)
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+// Make manager application call proposing a call of method with args, for JNA holders.
+// Account arguments can not be the sender of the executed call, they would be encoded as index 0.
+func Propose(algodClient *algod.Client, acct crypto.Account, jna uint64, method string, args []interface{}, contract_json string) (id uint64, err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	governed, err := governedCall(contract, types.Address{}, method, args, txParams)
+	if err != nil {
+		return
+	}
+	hash := CallHash(governed)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+	mcp := future.AddMethodCallParams{
+		AppID:           contract.Networks["default"].AppID,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "propose"), []interface{}{jna, hash}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	ret := debugAppCall(algodClient, atc, "./dryrun/propose.msgp", "./dryrun/response/propose.json")
+	id = ret[0].ReturnValue.(uint64)
+	return
+}
+
+// Opt in to the manager app, to lock JNA in votes
+func GovernanceOptIn(algodClient *algod.Client, acct crypto.Account, mng uint64) (err error) {
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	txn, err := future.MakeApplicationOptInTx(mng, nil, nil, nil, nil, txParams, acct.Address, nil, types.Digest{}, [32]byte{}, types.Address{})
+	if err != nil {
+		log.Fatalf("Failed to make optin txn: %+v", err)
+	}
+	err = signSendWait(algodClient, acct.PrivateKey, txn)
+	return
+}
+
+// Make manager application call voting on a proposal with amt JNA, locked until voting ends
+func Vote(algodClient *algod.Client, acct crypto.Account, jna, id, amt uint64, inFavour bool, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mng := contract.Networks["default"].AppID
+	mcp := future.AddMethodCallParams{
+		AppID:           mng,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	txn, _ := future.MakeAssetTransferTxn(acct.Address.String(), crypto.GetApplicationAddress(mng).String(), amt, nil, txParams, "", jna)
+	stxn := future.TransactionWithSigner{Txn: txn, Signer: signer}
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "vote"), []interface{}{stxn, id, inFavour}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	debugAppCall(algodClient, atc, "./dryrun/vote.msgp", "./dryrun/response/vote.json")
+	return
+}
+
+// Make manager application call returning JNA locked in a vote
+func Unlock(algodClient *algod.Client, acct crypto.Account, jna uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	// pay for the JNA transfer back
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(2 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           contract.Networks["default"].AppID,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "unlock"), []interface{}{jna}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	debugAppCall(algodClient, atc, "./dryrun/unlock.msgp", "./dryrun/response/unlock.json")
+	return
+}
+
+// Make manager application calls executing a passed proposal: execute followed by the governed call.
+// fees pays for the inner transactions of the governed call.
+func ExecuteProposal(algodClient *algod.Client, acct crypto.Account, id uint64, method string, args []interface{}, fees uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+
+	mng := contract.Networks["default"].AppID
+	proposals, _, err := Proposals(algodClient, mng)
+	if err != nil {
+		return
+	}
+	governed, err := governedCall(contract, acct.Address, method, args, txParams)
+	if err != nil {
+		return
+	}
+	hash := CallHash(governed)
+	found := false
+	for _, p := range proposals {
+		if p.ID == id {
+			if !bytes.Equal(p.Hash[:], hash[:]) {
+				return fmt.Errorf("%s call does not match proposal %d", method, id)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no open proposal %d", id)
+	}
+
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos((2 + fees) * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           mng,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "execute"), []interface{}{id}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+	txParams.Fee = 0
+	mcp.SuggestedParams = txParams
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, method), args))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	debugAppCall(algodClient, atc, "./dryrun/execute.msgp", "./dryrun/response/execute.json")
+	return
+}
+
+// Make manager application call removing a proposal left unexecuted for a window after voting
+func CloseProposal(algodClient *algod.Client, acct crypto.Account, id uint64, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "close_proposal", []interface{}{id}, contract_json)
+}
+
+// Make manager application call to set the quorum and voting window of proposals,
+// once governance is enabled it has to be proposed and executed
+func SetGovernance(algodClient *algod.Client, acct crypto.Account, g Governance, contract_json string) (err error) {
+	if g.Window == 0 {
+		return fmt.Errorf("zero voting window")
+	}
+	if g.Quorum == 0 {
+		return fmt.Errorf("zero quorum")
+	}
+	return callMethod(algodClient, acct, "set_governance", []interface{}{g.Quorum, g.Window}, contract_json)
+}
+
+// Make manager application call requiring executed proposals for parameter changes and upgrades, for good
+func EnableGovernance(algodClient *algod.Client, acct crypto.Account, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "enable_governance", nil, contract_json)
+}
@@ -0,0 +1,78 @@
+package jina
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestDecodeProposal(t *testing.T) {
+	value := make([]byte, 56)
+	value[0] = 0xaa
+	binary.BigEndian.PutUint64(value[32:], 1000)
+	binary.BigEndian.PutUint64(value[40:], 300)
+	binary.BigEndian.PutUint64(value[48:], 200)
+
+	p, ok := decodeProposal(proposalKey(7), value)
+	if !ok || p.ID != 7 || p.Hash[0] != 0xaa || p.End != 1000 || p.Yes != 300 || p.No != 200 {
+		t.Fatalf("wrong proposal %+v", p)
+	}
+	if !p.Passed(500) || p.Passed(501) {
+		t.Errorf("quorum of 500 votes not applied")
+	}
+	if (Proposal{Yes: 300, No: 300}).Passed(0) {
+		t.Errorf("tied proposal passed")
+	}
+	if _, ok = decodeProposal(collateralKey(7), make([]byte, 24)); ok {
+		t.Errorf("decoded proposal from collateral entry")
+	}
+}
+
+func TestCallHash(t *testing.T) {
+	contract, err := getContract("./abi/manager.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := contract.Networks["default"]
+	network.AppID = 1
+	contract.Networks["default"] = network
+	var sp types.SuggestedParams
+	rate := func(r uint64) [32]byte {
+		txn, err := governedCall(contract, types.Address{}, "set_rate", []interface{}{r}, sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return CallHash(txn)
+	}
+	if rate(5) != rate(5) {
+		t.Errorf("hash of the same call differs")
+	}
+	if rate(5) == rate(6) {
+		t.Errorf("hash ignores arguments")
+	}
+	a, err := governedCall(contract, types.Address{}, "remove_collateral", []interface{}{uint64(2)}, sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := governedCall(contract, types.Address{}, "remove_collateral", []interface{}{uint64(3)}, sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CallHash(a) == CallHash(b) {
+		t.Errorf("hash ignores foreign assets")
+	}
+}
+
+func TestParseMethodArgs(t *testing.T) {
+	values, err := ParseMethodArgs("set_risk", []string{"85", "300", "105", "95", "100"}, "./abi/manager.json")
+	if err != nil || len(values) != 5 || values[0] != uint64(85) {
+		t.Errorf("set_risk args = %v, %v", values, err)
+	}
+	if _, err = ParseMethodArgs("set_rate", []string{"1", "2"}, "./abi/manager.json"); err == nil {
+		t.Errorf("extra argument accepted")
+	}
+	if _, err = ParseMethodArgs("add_signer", []string{"not an address"}, "./abi/manager.json"); err == nil {
+		t.Errorf("bad address accepted")
+	}
+}
@@ -0,0 +1,112 @@
+package jina
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// Insurance is the insurance reserve of a market: JUSD jina sent to the manager to cover written off loans
+type Insurance struct {
+	Market
+	Share     uint64 // "ins" of the manager, basis points of lender fees
+	Received  uint64 // "ifee" of jina
+	Spent     uint64 // "icov" of jina
+	Shortfall uint64 // "short" of jina, loss taken by the reserve and not yet covered, in USDCa
+}
+
+// Reserve is the JUSD left to cover shortfalls
+func (i Insurance) Reserve() uint64 {
+	return i.Received - i.Spent
+}
+
+// Insured mirrors jina write_off: the part of a loss the reserve takes before JUSD holders,
+// at most its value at the redemption rate less the shortfall already pending
+func (i Insurance) Insured(loss, rate uint64) uint64 {
+	value := mulDiv(i.Reserve(), rate, RedemptionScale)
+	if value < i.Shortfall {
+		return 0
+	}
+	if value-i.Shortfall < loss {
+		return value - i.Shortfall
+	}
+	return loss
+}
+
+// CoverAmount is the reserve JUSD that pays as much of the shortfall as jina accepts
+func (i Insurance) CoverAmount(rate uint64) uint64 {
+	if rate == 0 {
+		return 0
+	}
+	amt := mulDiv(i.Shortfall, RedemptionScale, rate)
+	if amt > i.Reserve() {
+		amt = i.Reserve()
+	}
+	return amt
+}
+
+// ReadInsurance reads the insurance share and reserve of a market
+func ReadInsurance(algodClient *algod.Client, mng uint64, m Market) (i Insurance, err error) {
+	i.Market = m
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	i.Share = state["ins"].Uint
+	state, err = globalState(algodClient, m.Jina)
+	if err != nil {
+		return
+	}
+	i.Received = state["ifee"].Uint
+	i.Spent = state["icov"].Uint
+	i.Shortfall = state["short"].Uint
+	return
+}
+
+// Make manager application call to set the share of lender fees sent to the insurance reserve, for the manager creator only
+func SetInsuranceShare(algodClient *algod.Client, acct crypto.Account, share uint64, contract_json string) (err error) {
+	if share > 10000 {
+		return fmt.Errorf("insurance share %d above 10000 basis points", share)
+	}
+	return callMethod(algodClient, acct, "set_insurance", []interface{}{share}, contract_json)
+}
+
+// Make manager application call sending amt JUSD of a market's insurance reserve to cover its pending shortfall
+func Cover(algodClient *algod.Client, acct crypto.Account, m Market, amt uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	// pay for the JUSD transfer and jina call
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(3 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           contract.Networks["default"].AppID,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "cover"), []interface{}{m.Stablecoin, m.Jina, m.IOU, amt}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	debugAppCall(algodClient, atc, "./dryrun/cover.msgp", "./dryrun/response/cover.json")
+	return
+}
@@ -0,0 +1,39 @@
+package jina
+
+import "testing"
+
+func TestInsured(t *testing.T) {
+	i := Insurance{Received: 500, Spent: 100, Shortfall: 150}
+	if r := i.Reserve(); r != 400 {
+		t.Fatalf("reserve = %d, want 400", r)
+	}
+	// 250 of the reserve is not pending yet
+	if c := i.Insured(100, RedemptionScale); c != 100 {
+		t.Errorf("insured = %d, want the whole loss", c)
+	}
+	if c := i.Insured(1000, RedemptionScale); c != 250 {
+		t.Errorf("insured = %d, want 250", c)
+	}
+	// at half the redemption rate the reserve is worth 200
+	if c := i.Insured(1000, RedemptionScale/2); c != 50 {
+		t.Errorf("insured at half rate = %d, want 50", c)
+	}
+	i.Shortfall = 500
+	if c := i.Insured(1000, RedemptionScale); c != 0 {
+		t.Errorf("insured with reserve spent = %d", c)
+	}
+}
+
+func TestCoverAmount(t *testing.T) {
+	i := Insurance{Received: 500, Shortfall: 150}
+	if a := i.CoverAmount(RedemptionScale); a != 150 {
+		t.Errorf("cover amount = %d, want 150", a)
+	}
+	if a := i.CoverAmount(RedemptionScale / 2); a != 300 {
+		t.Errorf("cover amount at half rate = %d, want 300", a)
+	}
+	i.Shortfall = 1000
+	if a := i.CoverAmount(RedemptionScale); a != 500 {
+		t.Errorf("cover amount = %d, want the whole reserve", a)
+	}
+}
@@ -0,0 +1,56 @@
+package jina
+
+import (
+	"math/bits"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+// InterestScale is the denominator of the per-round interest rate (parts per billion)
+const InterestScale = 1000000000
+
+// AccruedDebt mirrors the contracts: lamt plus lamt*rate*(round-start)/1e9.
+// It panics where the contracts fail, when any step overflows uint64.
+func AccruedDebt(lamt, rate, start, round uint64) uint64 {
+	if round <= start {
+		return lamt
+	}
+	hi, factor := bits.Mul64(rate, round-start)
+	if hi != 0 {
+		panic("jina: interest factor overflows uint64")
+	}
+	hi, lo := bits.Mul64(lamt, factor)
+	if hi >= InterestScale {
+		panic("jina: interest overflows uint64")
+	}
+	interest, _ := bits.Div64(hi, lo, InterestScale)
+	debt, carry := bits.Add64(lamt, interest, 0)
+	if carry != 0 {
+		panic("jina: debt overflows uint64")
+	}
+	return debt
+}
+
+// Accrue returns the position with interest up to round added to its loan
+func (p Position) Accrue(rate, round uint64) Position {
+	p.Lamt = AccruedDebt(p.Lamt, rate, p.Start, round)
+	if round > p.Start {
+		p.Start = round
+	}
+	return p
+}
+
+// InterestRate reads the per-round loan interest rate from the manager app
+func InterestRate(algodClient *algod.Client, mng uint64) (rate uint64, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	return state["rate"].Uint, nil
+}
+
+// Make manager application call to set the per-round loan interest rate, in parts per billion
+func SetInterestRate(algodClient *algod.Client, acct crypto.Account, rate uint64, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "set_rate", []interface{}{rate}, contract_json)
+}
@@ -0,0 +1,46 @@
+package jina
+
+import "testing"
+
+func TestAccruedDebt(t *testing.T) {
+	// 10 parts per billion per round is about 7% a year at 4.5s rounds
+	if got := AccruedDebt(1000000000, 10, 100, 100+17280); got != 1000172800 {
+		t.Errorf("accrued debt after a day = %d", got)
+	}
+	if got := AccruedDebt(1000000000, 10, 100, 50); got != 1000000000 {
+		t.Errorf("accrued debt before start = %d", got)
+	}
+	if got := AccruedDebt(1000000000, 0, 100, 5000); got != 1000000000 {
+		t.Errorf("accrued debt without rate = %d", got)
+	}
+}
+
+func TestAccruedDebtOverflow(t *testing.T) {
+	cases := []struct {
+		lamt, rate, rounds uint64
+	}{
+		{1, 1 << 40, 1 << 30},          // rate times rounds
+		{1 << 62, 1000000000, 1 << 10}, // lamt times the factor over 1e9
+		{1 << 63, 1000000000, 1},       // the debt itself
+	}
+	for _, c := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("AccruedDebt(%d, %d, 0, %d) did not panic", c.lamt, c.rate, c.rounds)
+				}
+			}()
+			AccruedDebt(c.lamt, c.rate, 0, c.rounds)
+		}()
+	}
+}
+
+func TestPositionAccrue(t *testing.T) {
+	p := Position{Camt: 20, Lamt: 1000, Start: 10}.Accrue(100000000, 20)
+	if p.Lamt != 2000 || p.Start != 20 {
+		t.Errorf("wrong accrued position %+v", p)
+	}
+	if !p.Unhealthy(100) {
+		t.Errorf("accrued interest does not count towards liquidation")
+	}
+}
@@ -3,8 +3,8 @@ package jina
 import (
 	"context"
 	"crypto/ed25519"
-	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -132,7 +132,12 @@ func signSendWait(algodClient *algod.Client, sk ed25519.PrivateKey, txn types.Tr
 }
 
 // Make Jina application call to earn USDCa at 3%
-func Earn(algodClient *algod.Client, acct crypto.Account, xids []uint64, aamt, lvr uint64, lsa []byte, contract_json string) (err error) {
+func Earn(algodClient *algod.Client, acct crypto.Account, jina uint64, xids []uint64, aamt, lvr uint64, lsa []byte, contract_json string) (err error) {
+	return EarnWithTerms(algodClient, acct, jina, xids, aamt, lvr, DefaultFeeRate, 0, lsa, contract_json)
+}
+
+// Make Jina application call to earn USDCa at feeRate basis points, lending up to maxLTV percent of collateral value (0 for the liquidation threshold)
+func EarnWithTerms(algodClient *algod.Client, acct crypto.Account, jina uint64, xids []uint64, aamt, lvr, feeRate, maxLTV uint64, lsa []byte, contract_json string) (err error) {
 	f, err := os.Open(contract_json)
 	if err != nil {
 		log.Fatalf("Failed to open contract file: %+v", err)
@@ -153,10 +158,17 @@ func Earn(algodClient *algod.Client, acct crypto.Account, xids []uint64, aamt, l
 		log.Fatalf("Failed to get suggeted params: %+v", err)
 	}
 
+	// maxLTV is checked against the liquidation threshold of jina's manager
+	state, err := globalState(algodClient, jina)
+	if err != nil {
+		log.Fatalf("Failed to read jina state: %+v", err)
+	}
+	mng := state["mng"].Uint
+
 	signer := future.BasicAccountTransactionSigner{Account: acct}
 
 	mcp := future.AddMethodCallParams{
-		AppID:           contract.Networks["default"].AppID,
+		AppID:           jina,
 		Sender:          acct.Address,
 		SuggestedParams: txParams,
 		OnComplete:      types.NoOpOC,
@@ -164,7 +176,7 @@ func Earn(algodClient *algod.Client, acct crypto.Account, xids []uint64, aamt, l
 	}
 
 	var atc future.AtomicTransactionComposer
-	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "earn"), []interface{}{xids, aamt, lvr, lsa}))
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "earn"), []interface{}{xids, aamt, lvr, lsa, feeRate, maxLTV, mng}))
 	if err != nil {
 		log.Fatalf("Failed to AddMethodCall: %+v", err)
 	}
@@ -173,7 +185,7 @@ func Earn(algodClient *algod.Client, acct crypto.Account, xids []uint64, aamt, l
 	return
 }
 
-func Optin(algodClient *algod.Client, acct crypto.Account, app uint64, contract_json string) (err error) {
+func Optin(algodClient *algod.Client, acct crypto.Account, jina, app uint64, contract_json string) (err error) {
 	f, err := os.Open(contract_json)
 	if err != nil {
 		log.Fatalf("Failed to open contract file: %+v", err)
@@ -197,7 +209,7 @@ func Optin(algodClient *algod.Client, acct crypto.Account, app uint64, contract_
 	signer := future.BasicAccountTransactionSigner{Account: acct}
 
 	mcp := future.AddMethodCallParams{
-		AppID:           contract.Networks["default"].AppID,
+		AppID:           jina,
 		Sender:          acct.Address,
 		SuggestedParams: txParams,
 		OnComplete:      types.OptInOC,
@@ -215,7 +227,29 @@ func Optin(algodClient *algod.Client, acct crypto.Account, app uint64, contract_
 }
 
 // Make Jina application call to borrow against provided collateral
-func Borrow(algodClient *algod.Client, acct, lender crypto.Account, usdc, jusd, mng, lqt uint64, xids, camt, lamt []uint64, lsigFile, contract_json string) (err error) {
+func Borrow(algodClient *algod.Client, acct, lender crypto.Account, usdc, jusd, mng, jina, lqt uint64, xids, camt, lamt []uint64, lsigFile, contract_json string) (err error) {
+	return BorrowWithPrice(algodClient, acct, lender, usdc, jusd, mng, jina, lqt, xids, camt, lamt, SignedPrice{}, lsigFile, contract_json)
+}
+
+// Make Jina application call to borrow, valuing collateral with a signed price attestation
+func BorrowWithPrice(algodClient *algod.Client, acct, lender crypto.Account, usdc, jusd, mng, jina, lqt uint64, xids, camt, lamt []uint64, price SignedPrice, lsigFile, contract_json string) (err error) {
+	lsa, err := FetchLsigFromFile(lsigFile)
+	if err != nil {
+		log.Fatalf("Failed to get lsa from file: %+v", err)
+	}
+	return borrow(algodClient, acct, lender.Address, usdc, jusd, mng, jina, lqt, xids, camt, lamt, price, lsa, contract_json)
+}
+
+// Make Jina application call to borrow with the lender's lsig served by an lsig registry
+func BorrowFromRegistry(algodClient *algod.Client, acct crypto.Account, lender types.Address, usdc, jusd, mng, jina, lqt uint64, xids, camt, lamt []uint64, price SignedPrice, registry, contract_json string) (err error) {
+	lsa, err := FetchLsig(context.Background(), registry, lender)
+	if err != nil {
+		return
+	}
+	return borrow(algodClient, acct, lender, usdc, jusd, mng, jina, lqt, xids, camt, lamt, price, lsa, contract_json)
+}
+
+func borrow(algodClient *algod.Client, acct crypto.Account, lender types.Address, usdc, jusd, mng, jina, lqt uint64, xids, camt, lamt []uint64, price SignedPrice, lsa crypto.LogicSigAccount, contract_json string) (err error) {
 	f, err := os.Open(contract_json)
 	if err != nil {
 		log.Fatalf("Failed to open contract file: %+v", err)
@@ -236,12 +270,16 @@ func Borrow(algodClient *algod.Client, acct, lender crypto.Account, usdc, jusd,
 		log.Fatalf("Failed to get suggeted params: %+v", err)
 	}
 	txParams.FlatFee = true
-	txParams.Fee = types.MicroAlgos(4 * txParams.MinFee)
+	// the lender transfer and jina's inner transactions, its opup calls to the manager included
+	txParams.Fee = types.MicroAlgos(5 * txParams.MinFee)
+	if price.attested() {
+		txParams.Fee += types.MicroAlgos(attestationOpups * txParams.MinFee)
+	}
 
 	signer := future.BasicAccountTransactionSigner{Account: acct}
 
 	mcp := future.AddMethodCallParams{
-		AppID:           contract.Networks["default"].AppID,
+		AppID:           jina,
 		Sender:          acct.Address,
 		SuggestedParams: txParams,
 		OnComplete:      types.NoOpOC,
@@ -250,15 +288,18 @@ func Borrow(algodClient *algod.Client, acct, lender crypto.Account, usdc, jusd,
 
 	var atc future.AtomicTransactionComposer
 	txParams.Fee = 0
-	txn, _ := future.MakeAssetTransferTxn(lender.Address.String(), acct.Address.String(), lamt[0], nil, txParams, "", usdc)
-	lsa, err := FetchLsigFromFile(lsigFile)
+	txn, _ := future.MakeAssetTransferTxn(lender.String(), acct.Address.String(), lamt[0], nil, txParams, "", usdc)
+	// the lender's lsig only signs with the lsa of its current offer as note
+	state, err := localState(algodClient, lender.String(), jina)
 	if err != nil {
-		log.Fatalf("Failed to get lsa from file: %+v", err)
+		log.Fatalf("Failed to read lender offer: %+v", err)
 	}
+	txn.Note = stateBytes(state["lsa"])
 	signerLsa := future.LogicSigAccountTransactionSigner{LogicSigAccount: lsa}
 	//sig := future.BasicAccountTransactionSigner{Account: lender}
 	stxn := future.TransactionWithSigner{Txn: txn, Signer: signerLsa} //sig}
-	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "borrow"), []interface{}{stxn, xids, camt, lamt, lender.Address, xids[0], jusd, mng, lqt}))
+	args := append([]interface{}{stxn, xids, camt, lamt, lender, xids[0], jusd, mng, lqt}, price.args()...)
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "borrow"), args))
 	if err != nil {
 		log.Fatalf("Failed to AddMethodCall: %+v", err)
 	}
@@ -269,7 +310,7 @@ func Borrow(algodClient *algod.Client, acct, lender crypto.Account, usdc, jusd,
 }
 
 // Make Jina application call to repay loan and unfreeze asset
-func Repay(algodClient *algod.Client, acct crypto.Account, mng, lqt, usdc uint64, xids, ramt []uint64, contract_json string) (err error) {
+func Repay(algodClient *algod.Client, acct crypto.Account, mng, jina, lqt, usdc uint64, xids, ramt []uint64, contract_json string) (err error) {
 	f, err := os.Open(contract_json)
 	if err != nil {
 		log.Fatalf("Failed to open contract file: %+v", err)
@@ -294,7 +335,6 @@ func Repay(algodClient *algod.Client, acct crypto.Account, mng, lqt, usdc uint64
 
 	signer := future.BasicAccountTransactionSigner{Account: acct}
 
-	jina := contract.Networks["default"].AppID
 	mcp := future.AddMethodCallParams{
 		AppID:           jina,
 		Sender:          acct.Address,
@@ -311,13 +351,25 @@ func Repay(algodClient *algod.Client, acct crypto.Account, mng, lqt, usdc uint64
 	if err != nil {
 		log.Fatalf("Failed to AddMethodCall: %+v", err)
 	}
+	// the repaid USDCa pays the head of the redemption queue
+	q, err := ReadRedemptionQueue(algodClient, jina)
+	if err != nil {
+		log.Fatalf("Failed to read redemption queue: %+v", err)
+	}
+	if len(q.Redemptions) > 0 {
+		mcp.SuggestedParams.Fee = types.MicroAlgos(2 * txParams.MinFee)
+		err = atc.AddMethodCall(combine(mcp, getMethod(contract, "process_queue"), []interface{}{q.Redemptions[0].Claimant, usdc, mng}))
+		if err != nil {
+			log.Fatalf("Failed to AddMethodCall: %+v", err)
+		}
+	}
 
 	debugAppCall(algodClient, atc, "./dryrun/repay.msgp", "./dryrun/response/repay.json")
 	return
 }
 
 // Make Jina application call to claim USDCa for JUSD
-func Claim(algodClient *algod.Client, acct crypto.Account, mng, amt, usdc, jusd uint64, contract_json string) (err error) {
+func Claim(algodClient *algod.Client, acct crypto.Account, mng, jina, amt, usdc, jusd uint64, contract_json string) (err error) {
 	f, err := os.Open(contract_json)
 	if err != nil {
 		log.Fatalf("Failed to open contract file: %+v", err)
@@ -342,7 +394,6 @@ func Claim(algodClient *algod.Client, acct crypto.Account, mng, amt, usdc, jusd
 
 	signer := future.BasicAccountTransactionSigner{Account: acct}
 
-	jina := contract.Networks["default"].AppID
 	mcp := future.AddMethodCallParams{
 		AppID:           jina,
 		Sender:          acct.Address,
@@ -364,6 +415,58 @@ func Claim(algodClient *algod.Client, acct crypto.Account, mng, amt, usdc, jusd
 	return
 }
 
+// Make liquidator application call to liquidate an unhealthy loan, paying amt of usdc or jusd
+func Liquidate(algodClient *algod.Client, acct crypto.Account, liquidatee, receiver types.Address, mng, jina, lqt, xaid, pay, amt uint64, price SignedPrice, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(3 * txParams.MinFee)
+	if price.attested() {
+		txParams.Fee += types.MicroAlgos(attestationOpups * txParams.MinFee)
+	}
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           lqt,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	txParams.Fee = 0
+	txn, _ := future.MakeAssetTransferTxn(acct.Address.String(), crypto.GetApplicationAddress(lqt).String(), amt, nil, txParams, "", pay)
+	stxn := future.TransactionWithSigner{Txn: txn, Signer: signer}
+	args := append([]interface{}{stxn, liquidatee, receiver, xaid, pay, mng, jina}, price.args()...)
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "liquidate"), args))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+	if price.attested() {
+		err = addOpups(&atc, mcp, mng)
+		if err != nil {
+			log.Fatalf("Failed to add opup calls: %+v", err)
+		}
+	}
+	// the liquidator pays jina's address, which must be referenced in the call
+	atc, err = withAccounts(atc, 1, crypto.GetApplicationAddress(jina))
+	if err != nil {
+		return
+	}
+
+	_, err = atc.Execute(algodClient, context.Background(), 2)
+	return
+}
+
 func ConfigureApps(algodClient *algod.Client, acct crypto.Account, lqt, jina, usdc, jusd uint64, contract_json string) (err error) {
 	f, err := os.Open(contract_json)
 	if err != nil {
@@ -445,7 +548,7 @@ func CreateApps(algodClient *algod.Client, acct crypto.Account, usdc uint64, lqt
 
 	var atc future.AtomicTransactionComposer
 	var atc2 future.AtomicTransactionComposer
-	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "create_liquidator"), []interface{}{lqtApproval, lqtClear}))
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "create_liquidator"), []interface{}{usdc, lqtApproval, lqtClear}))
 	if err != nil {
 		log.Fatalf("Failed to AddMethodCall, create_liquidator: %+v", err)
 	}
@@ -454,15 +557,21 @@ func CreateApps(algodClient *algod.Client, acct crypto.Account, usdc uint64, lqt
 	ret := debugAppCall(algodClient, atc, "./dryrun/create_liquidator.msgp", "./dryrun/response/create_liquidator.json")
 	lqt = ret[0].ReturnValue.(uint64)
 
+	txParams.Fee = types.MicroAlgos(txParams.MinFee)
+	mcp.SuggestedParams = txParams
+	jinaEnd, err := addProgramChunks(&atc2, mcp, contract, jinaApproval)
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall, program_chunk: %+v", err)
+	}
 	txParams.Fee = types.MicroAlgos(4 * txParams.MinFee)
 	mcp.SuggestedParams = txParams
-	err = atc2.AddMethodCall(combine(mcp, getMethod(contract, "create_child"), []interface{}{usdc, jinaApproval, jinaClear, lqt}))
+	err = atc2.AddMethodCall(combine(mcp, getMethod(contract, "create_child"), []interface{}{usdc, jinaEnd, jinaClear, lqt}))
 	if err != nil {
 		log.Fatalf("Failed to AddMethodCall, create_child: %+v", err)
 	}
 
 	ret_j := debugAppCall(algodClient, atc2, "./dryrun/create_child.msgp", "./dryrun/response/create_child.json")
-	var v []interface{} = ret_j[0].ReturnValue.([]interface{})
+	var v []interface{} = ret_j[len(ret_j)-1].ReturnValue.([]interface{})
 	jina = v[0].(uint64)
 	ids[0] = lqt
 	ids[1] = jina
@@ -473,6 +582,21 @@ func CreateApps(algodClient *algod.Client, acct crypto.Account, usdc uint64, lqt
 	return
 }
 
+// programChunkLen keeps program_chunk and create_child calls under the 2048 bytes of app args of a call
+const programChunkLen = 1800
+
+// addProgramChunks adds program_chunk calls for a program too long for the app args of one call,
+// returning its end to pass to the call after them
+func addProgramChunks(atc *future.AtomicTransactionComposer, mcp future.AddMethodCallParams, contract *abi.Contract, program []byte) ([]byte, error) {
+	for len(program) > programChunkLen {
+		if err := atc.AddMethodCall(combine(mcp, getMethod(contract, "program_chunk"), []interface{}{program[:programChunkLen]})); err != nil {
+			return nil, err
+		}
+		program = program[programChunkLen:]
+	}
+	return program, nil
+}
+
 // Fund app
 func Fund(algodClient *algod.Client, acct crypto.Account, app, amt uint64) (err error) {
 	txParams, err := algodClient.SuggestedParams().Do(context.Background())
@@ -678,8 +802,9 @@ func Deploy(algodClient *algod.Client, acct crypto.Account, usdc uint64, contrac
 		Signer:          signer,
 		ApprovalProgram: app,
 		ClearProgram:    clear,
-		GlobalSchema:    types.StateSchema{NumUint: 6, NumByteSlice: 0},
-		LocalSchema:     types.StateSchema{NumUint: 0, NumByteSlice: 0},
+		GlobalSchema:    types.StateSchema{NumUint: 32, NumByteSlice: 32},
+		LocalSchema:     types.StateSchema{NumUint: 0, NumByteSlice: 1}, // locked JNA vote
+		ExtraPages:      3,
 	}
 
 	var atc future.AtomicTransactionComposer
@@ -737,26 +862,96 @@ func getMethod(c *abi.Contract, name string) (m abi.Method) {
 	return
 }
 
+// application call of a method with args, dry-run debugged like the other admin calls
+func callMethod(algodClient *algod.Client, acct crypto.Account, method string, args []interface{}, contract_json string) (err error) {
+	return callMethodWithNote(algodClient, acct, method, args, nil, contract_json)
+}
+
+// callMethod with a note attached to the application call
+func callMethodWithNote(algodClient *algod.Client, acct crypto.Account, method string, args []interface{}, note []byte, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+	return appCall(algodClient, acct, contract.Networks["default"].AppID, contract, method, args, note)
+}
+
+// callMethod on app instead of the app of the ABI file, for apps of which there is one per market
+func callAppMethod(algodClient *algod.Client, acct crypto.Account, app uint64, method string, args []interface{}, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+	return appCall(algodClient, acct, app, contract, method, args, nil)
+}
+
+func appCall(algodClient *algod.Client, acct crypto.Account, app uint64, contract *abi.Contract, method string, args []interface{}, note []byte) (err error) {
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           app,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+		Note:            note,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, method), args))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	debugAppCall(algodClient, atc, "./dryrun/"+method+".msgp", "./dryrun/response/"+method+".json")
+	return
+}
+
 func combine(mcp future.AddMethodCallParams, m abi.Method, a []interface{}) future.AddMethodCallParams {
 	mcp.Method = m
 	mcp.MethodArgs = a
 	return mcp
 }
 
-func getContract(file string) (err error) {
+// withAccounts rebuilds the group of atc adding accounts to the foreign accounts of txn i,
+// which AddMethodCallParams cannot set. The method results of the group are dropped.
+func withAccounts(atc future.AtomicTransactionComposer, i int, accounts ...types.Address) (out future.AtomicTransactionComposer, err error) {
+	txns, err := atc.BuildGroup()
+	if err != nil {
+		return
+	}
+	for j, txn := range txns {
+		txn.Txn.Group = types.Digest{}
+		if j == i {
+			txn.Txn.Accounts = append(txn.Txn.Accounts, accounts...)
+		}
+		if err = out.AddTransaction(txn); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func getContract(file string) (contract *abi.Contract, err error) {
 	f, err := os.Open(file)
 	if err != nil {
-		log.Fatalf("Failed to open contract file: %+v", err)
+		return nil, fmt.Errorf("failed to open contract file: %v", err)
 	}
+	defer f.Close()
 
 	b, err := ioutil.ReadAll(f)
 	if err != nil {
-		log.Fatalf("Failed to read file: %+v", err)
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
 	}
 
-	contract := &abi.Contract{}
-	if err := json.Unmarshal(b, contract); err != nil {
-		log.Fatalf("Failed to marshal contract: %+v", err)
+	contract = &abi.Contract{}
+	if err = json.Unmarshal(b, contract); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %v", file, err)
 	}
 	return
 }
@@ -794,20 +989,19 @@ func updateABI(algodClient *algod.Client, contract_json string, newApp uint64) {
 }
 
 func CompileSmartContractTeal(algodClient *algod.Client, osTealFile string) (compiledProgram []byte, err error) {
-	file, err := os.Open(osTealFile)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
-	tealFile, err := ioutil.ReadAll(file)
+	return CompileSmartContractTemplate(algodClient, osTealFile, nil)
+}
+
+// CompileSmartContractTemplate substitutes the TMPL_ placeholders of a TEAL file before compiling it
+func CompileSmartContractTemplate(algodClient *algod.Client, osTealFile string, params TemplateParams) (compiledProgram []byte, err error) {
+	tealFile, err := ReadTemplate(osTealFile, params)
 	if err != nil {
 		log.Fatalf("failed to read file: %s\n", err)
 	}
-	compileResponse, err := algodClient.TealCompile(tealFile).Do(context.Background())
+	compiledProgram, err = compileProgram(algodClient, tealFile)
 	if err != nil {
 		log.Fatalf("Issue with compile: %s\n", err)
 	}
-	compiledProgram, _ = base64.StdEncoding.DecodeString(compileResponse.Result)
 	log.Printf("%s size: %v\n", osTealFile, len(compiledProgram))
 	return
 }
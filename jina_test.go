@@ -1,8 +1,12 @@
+//go:build sandbox
+// +build sandbox
+
+// Integration tests against a local sandbox node, run with -tags sandbox
+
 package jina
 
 import (
 	"context"
-	"crypto/sha256"
 	"encoding/binary"
 	"log"
 	"strings"
@@ -38,7 +42,7 @@ func TestConfigASA(t *testing.T) {
 
 	acct := accts[2]
 
-	_, err = ConfigASA(algodClient, acct.PrivateKey, mng, jina, lqt, collateral)
+	err = ConfigASA(algodClient, acct.PrivateKey, mng, jina, lqt, collateral)
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
@@ -77,7 +81,7 @@ func TestCreateASA(t *testing.T) {
 
 	acct := accts[2]
 
-	err = CreateASA(algodClient, acct, 1000, 0, "LFT")
+	_, err = CreateASA(algodClient, acct, 1000, 0, "LFT", "")
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
@@ -314,7 +318,7 @@ func TestOptin(t *testing.T) {
 
 	acct := accts[2]
 
-	err = Optin(algodClient, acct, mng, "./abi/jina.json")
+	err = Optin(algodClient, acct, jina, mng, "./abi/jina.json")
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
@@ -348,13 +352,16 @@ func TestEarn(t *testing.T) {
 	lsigArgs[2] = buf[2][:]
 	lsigArgs[3] = buf[3][:]
 
-	lsaRaw := CompileToLsig(algodClient, lsigArgs, "./teal/logicSigDelegated.teal", "./codec/lender_lsig.codec", acct.PrivateKey)
+	lsa, err := NewOfferID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsaRaw := CompileLenderLsig(algodClient, lsigArgs, LenderTemplate{Offer: lsa, USDCa: usdc, Amount: aamt, LastValid: lvr, Jina: jina}, "./teal/logicSigDelegated.teal", "./codec/lender_lsig.codec", acct.PrivateKey)
 	if lsaRaw.SigningKey == nil {
 		t.Errorf("lsig is empty")
 	}
-	lsa := sha256.Sum256(lsaRaw.Lsig.Logic)
 
-	err = Earn(algodClient, acct, xids, aamt, lvr, lsa[:4], "./abi/jina.json")
+	err = Earn(algodClient, acct, jina, xids, aamt, lvr, lsa[:], "./abi/jina.json")
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
@@ -376,7 +383,7 @@ func TestClaim(t *testing.T) {
 
 	amt := uint64(10000000)
 
-	err = Claim(algodClient, acct, mng, amt, usdc, jusd, "./abi/jina.json")
+	err = Claim(algodClient, acct, mng, jina, amt, usdc, jusd, "./abi/jina.json")
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
@@ -400,7 +407,7 @@ func TestBorrow(t *testing.T) {
 	camt := []uint64{20}
 	lamt := []uint64{10000000}
 
-	err = Borrow(algodClient, acct, accts[0], usdc, jusd, mng, lqt, xids, camt, lamt, "./codec/lender_lsig.codec", "./abi/jina.json")
+	err = Borrow(algodClient, acct, accts[0], usdc, jusd, mng, jina, lqt, xids, camt, lamt, "./codec/lender_lsig.codec", "./abi/jina.json")
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
@@ -423,7 +430,7 @@ func TestRepay(t *testing.T) {
 	xids := []uint64{collateral}
 	ramt := []uint64{10000000}
 
-	err = Repay(algodClient, acct, mng, lqt, usdc, xids, ramt, "./abi/jina.json")
+	err = Repay(algodClient, acct, mng, jina, lqt, usdc, xids, ramt, "./abi/jina.json")
 	if err != nil {
 		t.Errorf("test found error, %s", err)
 	}
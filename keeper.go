@@ -0,0 +1,387 @@
+package jina
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// Position is one collateral slot of a borrower in jina local state
+type Position struct {
+	Borrower types.Address
+	AssetID  uint64
+	Camt     uint64 // collateral amount
+	Lamt     uint64 // loan amount, fee included
+	Start    uint64 // round interest accrues from
+	Maturity uint64 // round the loan is due, zero when it never matures
+}
+
+// Unhealthy mirrors the liquidator check under the default risk parameters
+func (p Position) Unhealthy(price uint64) bool {
+	return DefaultRiskParams.Unhealthy(p, price)
+}
+
+// LiquidationPayment is the least usdc or jusd the liquidator accepts under the default risk parameters
+func (p Position) LiquidationPayment() uint64 {
+	return DefaultRiskParams.LiquidationPayment(p)
+}
+
+// Value is the collateral worth at price, capped at the largest uint64 where the contracts' mulw goes beyond it
+func (p Position) Value(price uint64) uint64 {
+	hi, lo := bits.Mul64(p.Camt, price)
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
+// PositionsFromState reads the open positions of a borrower from its jina local state
+func PositionsFromState(borrower types.Address, state map[string]models.TealValue) (positions []Position) {
+	xids := uint64s(stateBytes(state["xids"]))
+	camt := uint64s(stateBytes(state["camt"]))
+	lamt := uint64s(stateBytes(state["lamt"]))
+	lrnd := uint64s(stateBytes(state["lrnd"]))
+	lmat := uint64s(stateBytes(state["lmat"]))
+	for i := range xids {
+		if i >= len(camt) || i >= len(lamt) || lamt[i] == 0 {
+			continue
+		}
+		p := Position{Borrower: borrower, AssetID: xids[i], Camt: camt[i], Lamt: lamt[i]}
+		if i < len(lrnd) {
+			p.Start = lrnd[i]
+		}
+		if i < len(lmat) {
+			p.Maturity = lmat[i]
+		}
+		positions = append(positions, p)
+	}
+	return
+}
+
+// Liquidation is a planned liquidation of a position
+type Liquidation struct {
+	Position
+	Price   uint64
+	Payment uint64
+	Profit  uint64 // collateral value less payment, in usdc micro units
+}
+
+// PlanLiquidation returns the liquidation of an unhealthy position if it earns at least minProfit
+func PlanLiquidation(p Position, price, minProfit uint64) (l Liquidation, ok bool) {
+	return DefaultRiskParams.PlanLiquidation(p, price, minProfit)
+}
+
+// PlanLiquidation returns the liquidation of a position unhealthy under r if it earns at least minProfit
+func (r RiskParams) PlanLiquidation(p Position, price, minProfit uint64) (l Liquidation, ok bool) {
+	if !r.Unhealthy(p, price) {
+		return
+	}
+	return r.plan(p, price, minProfit)
+}
+
+// plan prices the liquidation of a liquidatable position
+func (r RiskParams) plan(p Position, price, minProfit uint64) (l Liquidation, ok bool) {
+	l = Liquidation{Position: p, Price: price, Payment: r.LiquidationPayment(p)}
+	value := p.Value(price)
+	if value <= l.Payment {
+		return l, false
+	}
+	l.Profit = value - l.Payment
+	return l, l.Profit >= minProfit
+}
+
+// KeeperConfig configures the liquidation keeper
+type KeeperConfig struct {
+	Mng, Jina, Lqt uint64
+	Pay            uint64        // usdc or jusd paid to liquidate
+	Receiver       types.Address // receives the clawed back collateral, sender when zero
+	MinProfit      uint64        // usdc micro units a liquidation must earn to cover fees
+	StartRound     uint64        // first round scanned for borrowers
+	Borrowers      []types.Address
+	DryRun         bool
+	LqtContract    string // liquidator ABI file
+}
+
+// Keeper follows blocks, tracks jina positions and liquidates unhealthy ones
+type Keeper struct {
+	Config    KeeperConfig
+	positions map[types.Address][]Position
+	stale     map[types.Address]bool // borrowers whose last refresh failed, retried before evaluating
+	next      uint64
+	round     uint64 // last round seen, interest accrues up to its loan round
+
+	algodClient *algod.Client
+	// Price returns the oracle price of an asset
+	Price func(assetID uint64) (uint64, error)
+	// Rate returns the per-round loan interest rate
+	Rate func() (uint64, error)
+	// Risk returns the manager's risk parameters
+	Risk func() (RiskParams, error)
+	// Terms returns the manager's loan term and grace period
+	Terms func() (LoanTerms, error)
+	// LoanRound returns the round loans accrue interest and mature by at round
+	LoanRound func(round uint64) (uint64, error)
+	// State returns a borrower's jina local state, nil if not opted in
+	State func(borrower types.Address) (map[string]models.TealValue, error)
+	// Holding returns a borrower's balance of an asset, 0 if not opted in
+	Holding func(borrower types.Address, assetID uint64) (uint64, error)
+	// Liquidate submits a liquidation group
+	Liquidate func(l Liquidation) error
+}
+
+// NewKeeper makes a keeper liquidating from acct with the manager's on-chain price less the registry haircut
+func NewKeeper(algodClient *algod.Client, acct crypto.Account, cfg KeeperConfig) *Keeper {
+	k := &Keeper{
+		Config:      cfg,
+		positions:   make(map[types.Address][]Position),
+		stale:       make(map[types.Address]bool),
+		next:        cfg.StartRound,
+		algodClient: algodClient,
+	}
+	k.Price = func(assetID uint64) (uint64, error) {
+		price, _, err := ReadPrice(algodClient, cfg.Mng, assetID)
+		if err == nil && price == 0 {
+			err = fmt.Errorf("no price published for asset %d", assetID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		// the liquidator values registered collateral less its haircut
+		c, _, err := ReadCollateral(algodClient, cfg.Mng, assetID)
+		return c.Price(price), err
+	}
+	k.Rate = func() (uint64, error) {
+		return InterestRate(algodClient, cfg.Mng)
+	}
+	k.Risk = func() (RiskParams, error) {
+		return ReadRiskParams(algodClient, cfg.Mng)
+	}
+	k.Terms = func() (LoanTerms, error) {
+		return ReadLoanTerms(algodClient, cfg.Mng)
+	}
+	k.LoanRound = func(round uint64) (uint64, error) {
+		return LoanRound(algodClient, cfg.Mng, round)
+	}
+	k.State = func(borrower types.Address) (map[string]models.TealValue, error) {
+		state, err := localState(algodClient, borrower.String(), cfg.Jina)
+		if notFound(err) {
+			return nil, nil
+		}
+		return state, err
+	}
+	k.Holding = func(borrower types.Address, assetID uint64) (uint64, error) {
+		holding, err := algodClient.AccountAssetInformation(borrower.String(), assetID).Do(context.Background())
+		if notFound(err) {
+			return 0, nil
+		}
+		return holding.AssetHolding.Amount, err
+	}
+	k.Liquidate = func(l Liquidation) error {
+		receiver := cfg.Receiver
+		if receiver.IsZero() {
+			receiver = acct.Address
+		}
+		return Liquidate(algodClient, acct, l.Borrower, receiver, cfg.Mng, cfg.Jina, cfg.Lqt, l.AssetID, cfg.Pay, l.Payment, SignedPrice{}, cfg.LqtContract)
+	}
+	return k
+}
+
+// Positions returns the tracked open positions ordered by borrower
+func (k *Keeper) Positions() (positions []Position) {
+	for _, ps := range k.positions {
+		positions = append(positions, ps...)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.Borrower != b.Borrower {
+			return a.Borrower.String() < b.Borrower.String()
+		}
+		return a.AssetID < b.AssetID
+	})
+	return
+}
+
+// Track replaces the positions of a borrower
+func (k *Keeper) Track(borrower types.Address, positions []Position) {
+	if len(positions) == 0 {
+		delete(k.positions, borrower)
+		return
+	}
+	k.positions[borrower] = positions
+}
+
+// Refresh reloads a borrower's positions from jina local state.
+// A failed request leaves the borrower's tracked positions as they were, stale until a refresh succeeds.
+func (k *Keeper) Refresh(borrower types.Address) error {
+	// closed out accounts have no local state and no positions
+	state, err := k.State(borrower)
+	if err != nil {
+		k.stale[borrower] = true
+		return err
+	}
+	var positions []Position
+	for _, p := range PositionsFromState(borrower, state) {
+		// collateral already clawed back can not be liquidated again
+		amt, err := k.Holding(borrower, p.AssetID)
+		if err != nil {
+			k.stale[borrower] = true
+			return err
+		}
+		if amt < p.Camt {
+			continue
+		}
+		positions = append(positions, p)
+	}
+	k.Track(borrower, positions)
+	delete(k.stale, borrower)
+	return nil
+}
+
+// Run scans blocks from StartRound and evaluates positions after each new block
+func (k *Keeper) Run(ctx context.Context) error {
+	for _, b := range k.Config.Borrowers {
+		if err := k.Refresh(b); err != nil {
+			return err
+		}
+	}
+	status, err := k.algodClient.Status().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if k.next == 0 {
+		k.next = status.LastRound
+	}
+	for {
+		for ; k.next <= status.LastRound; k.next++ {
+			block, err := k.algodClient.Block(k.next).Do(ctx)
+			if err != nil {
+				return fmt.Errorf("block %d: %v", k.next, err)
+			}
+			for _, addr := range JinaAccountsInBlock(block, k.Config.Jina) {
+				if err := k.Refresh(addr); err != nil {
+					log.Printf("refresh %s: %v", addr, err)
+				}
+			}
+		}
+		k.round = status.LastRound
+		k.Evaluate()
+		status, err = k.algodClient.StatusAfterBlock(status.LastRound).Do(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+}
+
+// Evaluate prices every tracked position and liquidates the profitable unhealthy ones
+func (k *Keeper) Evaluate() (done []Liquidation) {
+	// positions that failed to refresh may already be repaid or liquidated
+	for b := range k.stale {
+		if err := k.Refresh(b); err != nil {
+			log.Printf("refresh %s: %v, skipping evaluation", b, err)
+			return
+		}
+	}
+	prices := make(map[uint64]uint64)
+	rate, err := k.Rate()
+	if err != nil {
+		log.Printf("interest rate: %v", err)
+		return
+	}
+	risk, err := k.Risk()
+	if err != nil {
+		log.Printf("risk parameters: %v", err)
+		return
+	}
+	terms, err := k.Terms()
+	if err != nil {
+		log.Printf("loan terms: %v", err)
+		return
+	}
+	round, err := k.LoanRound(k.round)
+	if err != nil {
+		log.Printf("loan round: %v", err)
+		return
+	}
+	for _, p := range k.Positions() {
+		p = p.Accrue(rate, round)
+		price, ok := prices[p.AssetID]
+		if !ok {
+			var err error
+			price, err = k.Price(p.AssetID)
+			if err != nil {
+				log.Printf("asset %d: %v", p.AssetID, err)
+				continue
+			}
+			prices[p.AssetID] = price
+		}
+		l, ok := risk.PlanLiquidation(p, price, k.Config.MinProfit)
+		if p.Overdue(terms.Grace, round) {
+			l, ok = risk.PlanDefault(p, price, k.Config.MinProfit)
+		}
+		if !ok {
+			if risk.Unhealthy(p, price) {
+				log.Printf("%s asset %d unhealthy but unprofitable (lamt %d, value %d)", p.Borrower, p.AssetID, p.Lamt, p.Value(price))
+			}
+			continue
+		}
+		if k.Config.DryRun {
+			log.Printf("dry-run: liquidate %s asset %d camt %d paying %d for profit %d", p.Borrower, p.AssetID, p.Camt, l.Payment, l.Profit)
+			done = append(done, l)
+			continue
+		}
+		if err := k.Liquidate(l); err != nil {
+			log.Printf("liquidate %s asset %d: %v", p.Borrower, p.AssetID, err)
+			continue
+		}
+		log.Printf("liquidated %s asset %d camt %d paying %d", p.Borrower, p.AssetID, p.Camt, l.Payment)
+		done = append(done, l)
+		if err := k.Refresh(p.Borrower); err != nil {
+			log.Printf("refresh %s: %v, skipping the rest of the evaluation", p.Borrower, err)
+			return
+		}
+	}
+	return
+}
+
+// JinaAccountsInBlock lists accounts whose jina local state may have changed in a block
+func JinaAccountsInBlock(block types.Block, jina uint64) (addrs []types.Address) {
+	seen := make(map[types.Address]bool)
+	var visit func(stxn types.SignedTxnWithAD)
+	visit = func(stxn types.SignedTxnWithAD) {
+		txn := stxn.Txn
+		if uint64(txn.ApplicationID) == jina && txn.Type == types.ApplicationCallTx {
+			for i := range stxn.EvalDelta.LocalDeltas {
+				var addr types.Address
+				if i == 0 {
+					addr = txn.Sender
+				} else if int(i) <= len(txn.Accounts) {
+					addr = txn.Accounts[i-1]
+				} else {
+					continue
+				}
+				if !seen[addr] {
+					seen[addr] = true
+					addrs = append(addrs, addr)
+				}
+			}
+		}
+		for _, inner := range stxn.EvalDelta.InnerTxns {
+			visit(inner)
+		}
+	}
+	for _, stxn := range block.Payset {
+		visit(stxn.SignedTxnWithAD)
+	}
+	return
+}
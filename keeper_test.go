@@ -0,0 +1,143 @@
+package jina
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func packed(vals ...uint64) models.TealValue {
+	b := make([]byte, 8*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint64(b[8*i:], v)
+	}
+	return models.TealValue{Type: 1, Bytes: base64.StdEncoding.EncodeToString(b)}
+}
+
+func TestPositionsFromState(t *testing.T) {
+	borrower := crypto.GenerateAccount().Address
+	state := map[string]models.TealValue{
+		"xids": packed(2, 7),
+		"camt": packed(20, 0),
+		"lamt": packed(10300000, 0),
+	}
+	positions := PositionsFromState(borrower, state)
+	if len(positions) != 1 {
+		t.Fatalf("expected 1 position, got %+v", positions)
+	}
+	p := positions[0]
+	if p.AssetID != 2 || p.Camt != 20 || p.Lamt != 10300000 || p.Borrower != borrower {
+		t.Errorf("wrong position %+v", p)
+	}
+}
+
+func TestPlanLiquidation(t *testing.T) {
+	p := Position{AssetID: 2, Camt: 20, Lamt: 1000}
+	if _, ok := PlanLiquidation(p, 60, 0); ok {
+		t.Errorf("healthy position planned for liquidation")
+	}
+	l, ok := PlanLiquidation(p, 55, 0)
+	if !ok || l.Payment != 1050 || l.Profit != 50 {
+		t.Errorf("wrong liquidation %+v %v", l, ok)
+	}
+	if _, ok = PlanLiquidation(p, 55, 51); ok {
+		t.Errorf("unprofitable liquidation planned")
+	}
+	if _, ok = PlanLiquidation(p, 50, 0); ok {
+		t.Errorf("liquidation paying more than collateral value planned")
+	}
+
+	// defaulted collateral worth more than a uint64 does not wrap around to an unprofitable value
+	large := Position{AssetID: 2, Camt: 1 << 40, Lamt: 1000}
+	if l, ok = DefaultRiskParams.PlanDefault(large, 1<<30, 0); !ok || l.Profit != math.MaxUint64-1050 {
+		t.Errorf("wrong liquidation of a large position %+v %v", l, ok)
+	}
+}
+
+func TestKeeperEvaluate(t *testing.T) {
+	a, b := crypto.GenerateAccount(), crypto.GenerateAccount()
+	k := NewKeeper(nil, a, KeeperConfig{DryRun: false})
+	k.Track(a.Address, []Position{{Borrower: a.Address, AssetID: 2, Camt: 20, Lamt: 1000}})
+	k.Track(b.Address, []Position{{Borrower: b.Address, AssetID: 3, Camt: 20, Lamt: 1000}})
+	k.Price = func(asset uint64) (uint64, error) { return map[uint64]uint64{2: 55, 3: 100}[asset], nil }
+	k.Rate = func() (uint64, error) { return 0, nil }
+	k.Risk = func() (RiskParams, error) { return DefaultRiskParams, nil }
+	k.Terms = func() (LoanTerms, error) { return LoanTerms{}, nil }
+	k.LoanRound = func(round uint64) (uint64, error) { return round, nil }
+	var liquidated []Liquidation
+	k.Liquidate = func(l Liquidation) error { liquidated = append(liquidated, l); return nil }
+	k.Config.DryRun = true
+	if done := k.Evaluate(); len(done) != 1 || len(liquidated) != 0 {
+		t.Errorf("dry-run liquidated %v, planned %v", liquidated, done)
+	}
+
+	// a failed refresh skips the tick until the borrower is refreshed
+	k.Config.DryRun = false
+	k.Price = func(uint64) (uint64, error) { return 55, nil }
+	var stateErr error
+	k.State = func(types.Address) (map[string]models.TealValue, error) { return nil, stateErr }
+	k.Holding = func(types.Address, uint64) (uint64, error) { return 0, nil }
+	stateErr = errors.New("HTTP 500: timeout")
+	if done := k.Evaluate(); len(done) != 1 || len(liquidated) != 1 {
+		t.Errorf("evaluated past a failed refresh, liquidated %v", liquidated)
+	}
+	if done := k.Evaluate(); len(done) != 0 || len(liquidated) != 1 {
+		t.Errorf("evaluated stale positions, liquidated %v", liquidated)
+	}
+	stateErr = nil
+	if done := k.Evaluate(); len(done) != 1 || len(liquidated) != 2 {
+		t.Errorf("liquidated %v once refreshed, want the second borrower", liquidated)
+	}
+}
+
+func TestKeeperRefresh(t *testing.T) {
+	a := crypto.GenerateAccount()
+	k := NewKeeper(nil, a, KeeperConfig{})
+	state := map[string]models.TealValue{"xids": packed(2), "camt": packed(20), "lamt": packed(1000)}
+	var stateErr, holdingErr error
+	k.State = func(types.Address) (map[string]models.TealValue, error) { return state, stateErr }
+	k.Holding = func(types.Address, uint64) (uint64, error) { return 20, holdingErr }
+	if err := k.Refresh(a.Address); err != nil || len(k.Positions()) != 1 {
+		t.Fatalf("refresh tracked %v, %v", k.Positions(), err)
+	}
+
+	// failed requests keep the borrower tracked
+	stateErr = errors.New("HTTP 500: timeout")
+	if err := k.Refresh(a.Address); err == nil || len(k.Positions()) != 1 {
+		t.Errorf("failed state request untracked the borrower: %v", err)
+	}
+	stateErr, holdingErr = nil, errors.New("connection refused")
+	if err := k.Refresh(a.Address); err == nil || len(k.Positions()) != 1 {
+		t.Errorf("failed holding request untracked the borrower: %v", err)
+	}
+
+	// closed out or repaid borrowers are untracked
+	state, holdingErr = nil, nil
+	if err := k.Refresh(a.Address); err != nil || len(k.Positions()) != 0 {
+		t.Errorf("closed out borrower still tracked %v, %v", k.Positions(), err)
+	}
+}
+
+func TestJinaAccountsInBlock(t *testing.T) {
+	borrower, other := crypto.GenerateAccount().Address, crypto.GenerateAccount().Address
+	call := types.SignedTxnWithAD{}
+	call.Txn.Type = types.ApplicationCallTx
+	call.Txn.Sender = borrower
+	call.Txn.ApplicationID = 6
+	call.Txn.Accounts = []types.Address{other}
+	call.EvalDelta.LocalDeltas = map[uint64]types.StateDelta{0: {}, 1: {}}
+	ignored := call
+	ignored.Txn.ApplicationID = 4
+	block := types.Block{Payset: []types.SignedTxnInBlock{{SignedTxnWithAD: call}, {SignedTxnWithAD: ignored}}}
+
+	addrs := JinaAccountsInBlock(block, 6)
+	if len(addrs) != 2 {
+		t.Errorf("expected borrower and account, got %v", addrs)
+	}
+}
@@ -0,0 +1,207 @@
+package jina
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// LenderTerms are what a lender's delegated lsig allows, baked into its program by Template.
+// The lsig carries them as args too, unsigned, for registries and borrowers to rebuild the program from
+type LenderTerms struct {
+	USDCa     uint64 // stablecoin withdrawn
+	Amount    uint64 // most a borrow can withdraw
+	LastValid uint64 // round the delegation expires
+	Jina      uint64 // jina app called in the borrow group
+}
+
+// Args encodes the terms as the lsig args, the program does not read them
+func (t LenderTerms) Args() [][]byte {
+	args := make([][]byte, 4)
+	for i, v := range []uint64{t.USDCa, t.Amount, t.LastValid, t.Jina} {
+		args[i] = make([]byte, 8)
+		binary.BigEndian.PutUint64(args[i], v)
+	}
+	return args
+}
+
+// OfferID is the lsa passed to earn and baked into the lsig as note,
+// the hash of the lender and terms so an offer with new terms invalidates the previous lsig
+func (t LenderTerms) OfferID(lender types.Address) (lsa [32]byte) {
+	b := append([]byte("jina-offer"), lender[:]...)
+	for _, arg := range t.Args() {
+		b = append(b, arg...)
+	}
+	return sha512.Sum512_256(b)
+}
+
+// Template are the values baked into the lender's lsig program for these terms
+func (t LenderTerms) Template(lender types.Address) LenderTemplate {
+	return LenderTemplate{Offer: t.OfferID(lender), USDCa: t.USDCa, Amount: t.Amount, LastValid: t.LastValid, Jina: t.Jina}
+}
+
+// LsigSigner signs a delegated program for the lender, a crypto.Account through AccountSigner or an external wallet
+type LsigSigner interface {
+	Address() types.Address
+	// SignProgram signs "Program"||program
+	SignProgram(program []byte) (types.Signature, error)
+}
+
+// AccountSigner signs lsigs with an account's private key
+type AccountSigner struct {
+	crypto.Account
+}
+
+func (s AccountSigner) Address() types.Address {
+	return s.Account.Address
+}
+
+func (s AccountSigner) SignProgram(program []byte) (sig types.Signature, err error) {
+	copy(sig[:], ed25519.Sign(s.PrivateKey, append([]byte("Program"), program...)))
+	return
+}
+
+// LenderLsig is a lender's signed delegated lsig with the terms it was made for
+type LenderLsig struct {
+	Lender  types.Address
+	Terms   LenderTerms
+	Created time.Time
+	Lsig    crypto.LogicSigAccount
+}
+
+// OfferID is the lsa of the offer the lsig is bound to
+func (l LenderLsig) OfferID() [32]byte {
+	return l.Terms.OfferID(l.Lender)
+}
+
+// Expired reports whether borrows can no longer use the lsig at round
+func (l LenderLsig) Expired(round uint64) bool {
+	return round > l.Terms.LastValid
+}
+
+// SignLenderLsig signs a lender program compiled for the terms, carrying them as args
+func SignLenderLsig(program []byte, terms LenderTerms, signer LsigSigner) (l LenderLsig, err error) {
+	sig, err := signer.SignProgram(program)
+	if err != nil {
+		return
+	}
+	lender := signer.Address()
+	lsig := types.LogicSig{Logic: program, Args: terms.Args(), Sig: sig}
+	if !crypto.VerifyLogicSig(lsig, lender) {
+		return l, fmt.Errorf("lsig signature does not verify for %s", lender)
+	}
+	pk := ed25519.PublicKey(lender[:])
+	l = LenderLsig{Lender: lender, Terms: terms, Created: time.Now().UTC()}
+	l.Lsig, err = crypto.LogicSigAccountFromLogicSig(lsig, &pk)
+	return
+}
+
+// NewLenderLsig compiles the lender lsig template for the terms and signs it
+func NewLenderLsig(algodClient *algod.Client, terms LenderTerms, signer LsigSigner, osTealFile string) (l LenderLsig, err error) {
+	tealFile, err := ReadTemplate(osTealFile, terms.Template(signer.Address()).Params())
+	if err != nil {
+		return
+	}
+	program, err := compileProgram(algodClient, tealFile)
+	if err != nil {
+		return
+	}
+	return SignLenderLsig(program, terms, signer)
+}
+
+// LsigKeystore keeps signed lender lsigs as JSON files in a directory
+type LsigKeystore struct {
+	Dir string
+}
+
+func (k LsigKeystore) path(lender types.Address, lsa [32]byte) string {
+	return filepath.Join(k.Dir, lender.String()+"-"+hex.EncodeToString(lsa[:])+".json")
+}
+
+// Save stores an lsig, replacing the one of the same offer
+func (k LsigKeystore) Save(l LenderLsig) error {
+	if err := os.MkdirAll(k.Dir, 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.path(l.Lender, l.OfferID()), b, 0600)
+}
+
+// List reads every stored lsig, oldest first
+func (k LsigKeystore) List() (lsigs []LenderLsig, err error) {
+	files, err := ioutil.ReadDir(k.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(k.Dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var l LenderLsig
+		if err = json.Unmarshal(b, &l); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		lsigs = append(lsigs, l)
+	}
+	sort.SliceStable(lsigs, func(i, j int) bool { return lsigs[i].Created.Before(lsigs[j].Created) })
+	return
+}
+
+// Find returns the stored lsig of a lender's offer
+func (k LsigKeystore) Find(lender types.Address, lsa [32]byte) (l LenderLsig, err error) {
+	b, err := ioutil.ReadFile(k.path(lender, lsa))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &l)
+	return
+}
+
+// Delete removes the stored lsig of a lender's offer
+func (k LsigKeystore) Delete(lender types.Address, lsa [32]byte) error {
+	return os.Remove(k.path(lender, lsa))
+}
+
+// SplitExpired separates the lsigs borrows can still use at round from the expired ones
+func SplitExpired(lsigs []LenderLsig, round uint64) (active, expired []LenderLsig) {
+	for _, l := range lsigs {
+		if l.Expired(round) {
+			expired = append(expired, l)
+		} else {
+			active = append(active, l)
+		}
+	}
+	return
+}
+
+// WriteCodec writes the lsig alone, as read by FetchLsigFromFile for Borrow
+func (l LenderLsig) WriteCodec(codecFile string) error {
+	b, err := json.MarshalIndent(l.Lsig, "", "")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(codecFile, b, 0644)
+}
@@ -0,0 +1,105 @@
+package jina
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+// testTerms are the lender terms of the lsig tests
+var testTerms = LenderTerms{USDCa: 10, Amount: 50000000, LastValid: 1000, Jina: 7}
+
+// testLender signs the test lsigs, its key is fixed so the offer ids baked into lenderPrograms are too
+func testLender() crypto.Account {
+	seed := make([]byte, ed25519.SeedSize)
+	copy(seed, "jina test lender")
+	acct, err := crypto.AccountFromPrivateKey(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		panic(err)
+	}
+	return acct
+}
+
+// lenderPrograms are logicSigDelegated.teal assembled for testLender and testTerms by amount,
+// TestLenderProgramsOffline checks them against the template
+var lenderPrograms = map[uint64]string{
+	50000000: "052001003203494931201244310912443115124431012212443111810a124431128180e1eb170e44310481e8070e44310580205e721c2c575f280e00f6968ea83cedf832a33cb26ed8fd5d959b4825e8769059124432048101094938108106124449381881071244493819221244391a008004ccaa52a51243",
+	49999999: "052001003203494931201244310912443115124431012212443111810a1244311281ffe0eb170e44310481e8070e4431058020f15decabee160bb93d69b4c94443e5743521bb73f70a90f39ab518c01f5fea0c124432048101094938108106124449381881071244493819221244391a008004ccaa52a51243",
+}
+
+// lenderProgram is the lender lsig program of testTerms lending amount
+func lenderProgram(t testing.TB, amount uint64) []byte {
+	program, err := hex.DecodeString(lenderPrograms[amount])
+	if err != nil || len(program) == 0 {
+		t.Fatalf("no lender program lending %d", amount)
+	}
+	return program
+}
+
+// testLenderLsig is testLender's signed lsig of testTerms lending amount
+func testLenderLsig(t testing.TB, amount uint64) LenderLsig {
+	terms := testTerms
+	terms.Amount = amount
+	l, err := SignLenderLsig(lenderProgram(t, amount), terms, AccountSigner{testLender()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLenderLsig(t *testing.T) {
+	acct := testLender()
+	terms := testTerms
+	args := terms.Args()
+	if len(args) != 4 || args[1][7] != 0x80 || args[3][7] != 7 {
+		t.Fatalf("wrong args %x", args)
+	}
+	lsa := terms.OfferID(acct.Address)
+	if lsa == terms.OfferID(crypto.GenerateAccount().Address) {
+		t.Errorf("offer id does not depend on the lender")
+	}
+	other := terms
+	other.Amount++
+	if lsa == other.OfferID(acct.Address) {
+		t.Errorf("offer id does not depend on the terms")
+	}
+
+	if _, err := NewLenderLsig(nil, terms, AccountSigner{acct}, "./teal/logicSigDelegated.teal"); err == nil {
+		t.Errorf("compiled the lsig without algod")
+	}
+
+	l := testLenderLsig(t, terms.Amount)
+	if addr, err := l.Lsig.Address(); err != nil || addr != acct.Address {
+		t.Errorf("lsig delegated by %s, %v", addr, err)
+	}
+	if l.Expired(1000) || !l.Expired(1001) {
+		t.Errorf("lsig should expire after round 1000")
+	}
+
+	ks := LsigKeystore{Dir: t.TempDir()}
+	if err := ks.Save(l); err != nil {
+		t.Fatal(err)
+	}
+	expired := l
+	expired.Terms.LastValid = 10
+	if err := ks.Save(expired); err != nil {
+		t.Fatal(err)
+	}
+	lsigs, err := ks.List()
+	if err != nil || len(lsigs) != 2 {
+		t.Fatalf("listed %d lsigs, %v", len(lsigs), err)
+	}
+	active, old := SplitExpired(lsigs, 500)
+	if len(active) != 1 || len(old) != 1 || active[0].OfferID() != lsa {
+		t.Errorf("active %+v, expired %+v", active, old)
+	}
+	found, err := ks.Find(acct.Address, lsa)
+	if err != nil || !crypto.VerifyLogicSig(found.Lsig.Lsig, acct.Address) {
+		t.Errorf("stored lsig does not verify, %v", err)
+	}
+	if err = ks.Delete(acct.Address, lsa); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,115 @@
+package jina
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// LsigReport is what InspectLenderLsig found in a lender's delegated lsig
+type LsigReport struct {
+	Lender         types.Address
+	Terms          LenderTerms   // read from the unsigned args, the baked values only when ProgramMatches
+	ProgramHash    types.Address // address of the program, the hash the template is compared by
+	SignatureValid bool
+	ProgramMatches bool
+	Warnings       []string
+}
+
+// Safe reports whether a borrower can rely on the lsig
+func (r LsigReport) Safe() bool {
+	return r.SignatureValid && r.ProgramMatches && len(r.Warnings) == 0
+}
+
+func (r *LsigReport) warn(format string, a ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, a...))
+}
+
+// DecodeLenderArgs decodes the lsig args arg_0 to arg_3 into terms
+func DecodeLenderArgs(args [][]byte) (t LenderTerms, err error) {
+	if len(args) != 4 {
+		return t, fmt.Errorf("%d lsig args, want 4", len(args))
+	}
+	vals := make([]uint64, 4)
+	for i, arg := range args {
+		if len(arg) != 8 {
+			return t, fmt.Errorf("arg_%d is %d bytes, want 8", i, len(arg))
+		}
+		vals[i] = binary.BigEndian.Uint64(arg)
+	}
+	return LenderTerms{USDCa: vals[0], Amount: vals[1], LastValid: vals[2], Jina: vals[3]}, nil
+}
+
+// ReadLsigFile decodes a codec file written by CompileLenderLsig or WriteCodec
+func ReadLsigFile(codecFile string) (lsa crypto.LogicSigAccount, err error) {
+	b, err := ioutil.ReadFile(codecFile)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &lsa)
+	return
+}
+
+// ExpectedLenderProgram compiles the lender lsig template for an offer
+func ExpectedLenderProgram(algodClient *algod.Client, osTealFile string, t LenderTemplate) (program []byte, err error) {
+	tealFile, err := ReadTemplate(osTealFile, t.Params())
+	if err != nil {
+		return
+	}
+	return compileProgram(algodClient, tealFile)
+}
+
+// InspectLenderLsig checks an lsig claimed to be delegated by lender against the expected program,
+// the market it should lend in (zero usdc or jina skip the check) and the current round.
+// program should be the template compiled for the terms in the lsig args, matching it proves they are baked in
+func InspectLenderLsig(lsa crypto.LogicSigAccount, lender types.Address, program []byte, usdc, jina, round uint64) (r LsigReport) {
+	r.Lender = lender
+	r.ProgramHash = crypto.AddressFromProgram(lsa.Lsig.Logic)
+	r.ProgramMatches = bytes.Equal(lsa.Lsig.Logic, program)
+	if !r.ProgramMatches {
+		r.warn("program %s is not the lender template, expected %s", r.ProgramHash, crypto.AddressFromProgram(program))
+	}
+
+	if lsa.Lsig.Msig.Version != 0 || len(lsa.Lsig.Msig.Subsigs) != 0 {
+		r.warn("lsig is delegated by a multisig")
+	} else if lsa.Lsig.Sig == (types.Signature{}) {
+		r.warn("lsig is an escrow, not delegated by the lender")
+	} else {
+		r.SignatureValid = crypto.VerifyLogicSig(lsa.Lsig, lender)
+		if !r.SignatureValid {
+			r.warn("signature does not verify for %s", lender)
+		}
+	}
+	if len(lsa.SigningKey) != 0 && !bytes.Equal(lsa.SigningKey, lender[:]) {
+		r.warn("signing key %x is not the lender", []byte(lsa.SigningKey))
+	}
+
+	terms, err := DecodeLenderArgs(lsa.Lsig.Args)
+	if err != nil {
+		r.warn("%v", err)
+		return
+	}
+	r.Terms = terms
+	if !r.ProgramMatches {
+		r.warn("terms are read from the unsigned lsig args, the program may allow others")
+	}
+	if terms.Amount == 0 {
+		r.warn("lends nothing")
+	}
+	if round > terms.LastValid {
+		r.warn("expired at round %d", terms.LastValid)
+	}
+	if usdc != 0 && terms.USDCa != usdc {
+		r.warn("lends asset %d, not %d", terms.USDCa, usdc)
+	}
+	if jina != 0 && terms.Jina != jina {
+		r.warn("requires jina app %d, not %d", terms.Jina, jina)
+	}
+	return
+}
@@ -0,0 +1,37 @@
+package jina
+
+import (
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+func TestInspectLenderLsig(t *testing.T) {
+	acct, terms := testLender(), testTerms
+	program := lenderProgram(t, terms.Amount)
+	l := testLenderLsig(t, terms.Amount)
+
+	r := InspectLenderLsig(l.Lsig, acct.Address, program, 10, 7, 900)
+	if !r.Safe() || r.Terms != terms {
+		t.Fatalf("unsafe report %+v", r)
+	}
+
+	other := crypto.GenerateAccount().Address
+	if r = InspectLenderLsig(l.Lsig, other, program, 10, 7, 900); r.SignatureValid || r.Safe() {
+		t.Errorf("lsig verified for another lender")
+	}
+	if r = InspectLenderLsig(l.Lsig, acct.Address, lenderProgram(t, terms.Amount-1), 10, 7, 900); r.ProgramMatches || len(r.Warnings) != 2 {
+		t.Errorf("want program and unsigned args warnings, got %q", r.Warnings)
+	}
+	if r = InspectLenderLsig(l.Lsig, acct.Address, program, 11, 8, 1001); len(r.Warnings) != 3 {
+		t.Errorf("want expiry, asset and app warnings, got %q", r.Warnings)
+	}
+
+	escrow := crypto.MakeLogicSigAccountEscrow(program, terms.Args())
+	if r = InspectLenderLsig(escrow, acct.Address, program, 0, 0, 0); r.Safe() {
+		t.Errorf("escrow lsig reported safe")
+	}
+	if _, err := DecodeLenderArgs(terms.Args()[:3]); err == nil {
+		t.Errorf("decoded 3 args")
+	}
+}
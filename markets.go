@@ -0,0 +1,82 @@
+package jina
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+// Market is one stablecoin market of the manager: its jina and liquidator apps and I-O-U token
+type Market struct {
+	Stablecoin uint64
+	Jina       uint64
+	Lqt        uint64
+	IOU        uint64
+}
+
+// marketKey mirrors the manager market_key subroutine: name||itob(stablecoin)
+func marketKey(name string, stablecoin uint64) string {
+	b := make([]byte, len(name)+8)
+	copy(b, name)
+	binary.BigEndian.PutUint64(b[len(name):], stablecoin)
+	return string(b)
+}
+
+// marketsFromState collects the markets whose apps and I-O-U token were all created
+func marketsFromState(state map[string]uint64) (markets []Market) {
+	for k, v := range state {
+		if len(k) != len("usdc")+8 || !strings.HasPrefix(k, "usdc") {
+			continue
+		}
+		m := Market{
+			Stablecoin: v,
+			Jina:       state[marketKey("jina", v)],
+			Lqt:        state[marketKey("lqt", v)],
+			IOU:        state[marketKey("jusd", v)],
+		}
+		if m.Jina != 0 && m.Lqt != 0 && m.IOU != 0 {
+			markets = append(markets, m)
+		}
+	}
+	sort.Slice(markets, func(i, j int) bool { return markets[i].Stablecoin < markets[j].Stablecoin })
+	return
+}
+
+// Markets enumerates the stablecoin markets created by the manager app
+func Markets(algodClient *algod.Client, mng uint64) (markets []Market, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	uints := make(map[string]uint64, len(state))
+	for k, v := range state {
+		uints[k] = v.Uint
+	}
+	return marketsFromState(uints), nil
+}
+
+// FindMarket returns the market of a stablecoin
+func FindMarket(markets []Market, stablecoin uint64) (m Market, err error) {
+	for _, m = range markets {
+		if m.Stablecoin == stablecoin {
+			return
+		}
+	}
+	return Market{}, fmt.Errorf("no market for stablecoin %d", stablecoin)
+}
+
+// CreateMarket creates and configures the jina, liquidator and I-O-U token of a stablecoin.
+// The jina and liquidator ABI files are left pointing at the new market.
+func CreateMarket(algodClient *algod.Client, acct crypto.Account, usdc uint64, lqtApproval, lqtClear, jinaApproval, jinaClear []byte, contract_json, lqt_contract, jina_contract string) (m Market, err error) {
+	ids, err := CreateApps(algodClient, acct, usdc, lqtApproval, lqtClear, jinaApproval, jinaClear, contract_json, lqt_contract, jina_contract)
+	if err != nil {
+		return
+	}
+	m = Market{Stablecoin: usdc, Lqt: ids[0], Jina: ids[1], IOU: ids[2]}
+	err = ConfigureApps(algodClient, acct, m.Lqt, m.Jina, m.Stablecoin, m.IOU, contract_json)
+	return
+}
@@ -0,0 +1,36 @@
+package jina
+
+import "testing"
+
+func TestMarketsFromState(t *testing.T) {
+	state := map[string]uint64{
+		"usdc":                10,
+		"jna":                 5,
+		marketKey("usdc", 10): 10,
+		marketKey("jina", 10): 11,
+		marketKey("lqt", 10):  12,
+		marketKey("jusd", 10): 13,
+		marketKey("usdc", 7):  7,
+		marketKey("jina", 7):  21,
+		marketKey("lqt", 7):   22,
+		marketKey("jusd", 7):  23,
+		// creation still in progress
+		marketKey("usdc", 30): 30,
+		marketKey("lqt", 30):  31,
+	}
+	markets := marketsFromState(state)
+	if len(markets) != 2 {
+		t.Fatalf("got %d markets, want 2: %+v", len(markets), markets)
+	}
+	want := Market{Stablecoin: 7, Jina: 21, Lqt: 22, IOU: 23}
+	if markets[0] != want {
+		t.Errorf("markets[0] = %+v, want %+v", markets[0], want)
+	}
+	m, err := FindMarket(markets, 10)
+	if err != nil || m.Jina != 11 || m.IOU != 13 {
+		t.Errorf("FindMarket = %+v, %v", m, err)
+	}
+	if _, err = FindMarket(markets, 30); err == nil {
+		t.Errorf("found incomplete market")
+	}
+}
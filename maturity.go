@@ -0,0 +1,63 @@
+package jina
+
+import (
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// LoanTerms are the manager's term of new loans and grace period before an overdue loan defaults, in rounds
+type LoanTerms struct {
+	Term  uint64 // zero for loans that never mature
+	Grace uint64
+}
+
+// Overdue mirrors the liquidator default check: not repaid, past maturity and the grace period
+func (p Position) Overdue(grace, round uint64) bool {
+	return p.Lamt != 0 && p.Maturity != 0 && round > p.Maturity+grace
+}
+
+// PlanDefault returns the liquidation of a defaulted position, at any price, if it earns at least minProfit
+func (r RiskParams) PlanDefault(p Position, price, minProfit uint64) (l Liquidation, ok bool) {
+	return r.plan(p, price, minProfit)
+}
+
+// MaturingLoans lists positions due within rounds after round, overdue ones included, soonest first
+func MaturingLoans(positions []Position, round, within uint64) (due []Position) {
+	for _, p := range positions {
+		if p.Maturity != 0 && p.Maturity <= round+within {
+			due = append(due, p)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Maturity < due[j].Maturity })
+	return
+}
+
+// Loans reads the open positions of borrowers from jina local state
+func Loans(algodClient *algod.Client, jina uint64, borrowers []types.Address) (positions []Position, err error) {
+	for _, b := range borrowers {
+		state, err := localState(algodClient, b.String(), jina)
+		if err != nil {
+			// closed out accounts have no local state
+			continue
+		}
+		positions = append(positions, PositionsFromState(b, state)...)
+	}
+	return
+}
+
+// ReadLoanTerms reads the loan term and grace period from the manager app
+func ReadLoanTerms(algodClient *algod.Client, mng uint64) (t LoanTerms, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	return LoanTerms{Term: state["term"].Uint, Grace: state["grace"].Uint}, nil
+}
+
+// Make manager application call to set the term of new loans and the grace period before default
+func SetLoanTerms(algodClient *algod.Client, acct crypto.Account, t LoanTerms, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "set_loan_terms", []interface{}{t.Term, t.Grace}, contract_json)
+}
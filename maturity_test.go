@@ -0,0 +1,39 @@
+package jina
+
+import "testing"
+
+func TestMaturingLoans(t *testing.T) {
+	positions := []Position{
+		{AssetID: 1, Maturity: 0},
+		{AssetID: 2, Maturity: 1500},
+		{AssetID: 3, Lamt: 1000, Maturity: 900},
+		{AssetID: 4, Maturity: 3000},
+	}
+	due := MaturingLoans(positions, 1000, 1000)
+	if len(due) != 2 || due[0].AssetID != 3 || due[1].AssetID != 2 {
+		t.Errorf("due = %+v, want assets 3 and 2", due)
+	}
+	if positions[2].Overdue(100, 1000) || !positions[2].Overdue(100, 1001) {
+		t.Errorf("default must start after the grace period")
+	}
+	if positions[0].Overdue(0, 1<<40) {
+		t.Errorf("loan without maturity overdue")
+	}
+	repaid := positions[2]
+	repaid.Lamt = 0
+	if repaid.Overdue(100, 1001) {
+		t.Errorf("repaid loan overdue")
+	}
+}
+
+func TestPlanDefault(t *testing.T) {
+	// healthy at 60, liquidatable only once in default
+	p := Position{AssetID: 2, Camt: 20, Lamt: 1000, Maturity: 10}
+	if _, ok := DefaultRiskParams.PlanLiquidation(p, 60, 0); ok {
+		t.Fatalf("healthy position planned for liquidation")
+	}
+	l, ok := DefaultRiskParams.PlanDefault(p, 60, 0)
+	if !ok || l.Payment != 1050 || l.Profit != 150 {
+		t.Errorf("default liquidation %+v %v", l, ok)
+	}
+}
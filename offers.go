@@ -0,0 +1,190 @@
+package jina
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// DefaultFeeRate is the lender fee of offers made without one, in basis points
+const DefaultFeeRate = 300
+
+// Offer is a lender's liquidity offer in jina local state
+type Offer struct {
+	Lender  types.Address
+	Xids    []uint64 // allowed collateral
+	Aamt    uint64   // available usdc
+	Lvr     uint64   // last valid round
+	FeeRate uint64   // basis points of the loan
+	MaxLTV  uint64   // percent of collateral value, 0 for the liquidation threshold
+	Lsa     []byte
+
+	Creators    []types.Address // collateral allowed by creator
+	Collections []uint64        // collateral allowed by manager collection registry
+}
+
+// Allows reports whether the offer lends amt against xaid at round
+func (o Offer) Allows(xaid, amt, round uint64) bool {
+	return o.Aamt >= amt && o.Lvr >= round && containsUint64(o.Xids, xaid)
+}
+
+// Fee is what a borrower pays the lender on a loan of amt
+func (o Offer) Fee(amt uint64) uint64 {
+	return amt * o.FeeRate / 10000
+}
+
+// OfferFromState reads the offer of a lender from its jina local state
+func OfferFromState(lender types.Address, state map[string]models.TealValue) (o Offer, ok bool) {
+	aamt, ok := state["aamt"]
+	if !ok {
+		return
+	}
+	o = Offer{
+		Lender:  lender,
+		Xids:    uint64s(stateBytes(state["xids"])),
+		Aamt:    aamt.Uint,
+		Lvr:     state["lvr"].Uint,
+		FeeRate: DefaultFeeRate,
+		MaxLTV:  state["ltv"].Uint,
+		Lsa:     stateBytes(state["lsa"]),
+	}
+	if fee, set := state["lfr"]; set {
+		o.FeeRate = fee.Uint
+	}
+	crt := stateBytes(state["crt"])
+	for i := 0; i+32 <= len(crt); i += 32 {
+		var creator types.Address
+		copy(creator[:], crt[i:i+32])
+		o.Creators = append(o.Creators, creator)
+	}
+	o.Collections = uint64s(stateBytes(state["cols"]))
+	return o, true
+}
+
+// CollectionRegistry maps collection IDs registered in the manager app to their creator
+type CollectionRegistry map[uint64]types.Address
+
+// Collections reads the collection registry of the manager app
+func Collections(algodClient *algod.Client, mng uint64) (reg CollectionRegistry, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	reg = make(CollectionRegistry)
+	for k, v := range state {
+		b := stateBytes(v)
+		if len(k) != 11 || k[:3] != "col" || len(b) != 32 {
+			continue
+		}
+		var creator types.Address
+		copy(creator[:], b)
+		reg[binary.BigEndian.Uint64([]byte(k[3:]))] = creator
+	}
+	return
+}
+
+// AcceptedCreators lists the creators whose assets the offer accepts, directly or by collection
+func (o Offer) AcceptedCreators(reg CollectionRegistry) (creators []types.Address) {
+	creators = append(creators, o.Creators...)
+	for _, id := range o.Collections {
+		if creator, ok := reg[id]; ok {
+			creators = append(creators, creator)
+		}
+	}
+	return
+}
+
+// AllowsCollateral mirrors lenders_allow_collateral: by asset ID, creator or registered collection
+func (o Offer) AllowsCollateral(xaid uint64, creator types.Address, reg CollectionRegistry) bool {
+	if containsUint64(o.Xids, xaid) {
+		return true
+	}
+	for _, c := range o.AcceptedCreators(reg) {
+		if c == creator {
+			return true
+		}
+	}
+	return false
+}
+
+// Offers reads the offers of lenders, skipping accounts without one
+func Offers(algodClient *algod.Client, jina uint64, lenders []types.Address) (offers []Offer, err error) {
+	for _, lender := range lenders {
+		var state map[string]models.TealValue
+		state, err = localState(algodClient, lender.String(), jina)
+		if notFound(err) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if o, ok := OfferFromState(lender, state); ok {
+			offers = append(offers, o)
+		}
+	}
+	return
+}
+
+// OrderBook lists the offers lending amt against xaid at round, cheapest first
+func OrderBook(offers []Offer, xaid, amt, round uint64) []Offer {
+	return orderBook(offers, func(o Offer) bool { return o.Allows(xaid, amt, round) })
+}
+
+// CollectionOrderBook is OrderBook including offers that allow the collateral by creator or collection
+func CollectionOrderBook(offers []Offer, xaid uint64, creator types.Address, reg CollectionRegistry, amt, round uint64) []Offer {
+	return orderBook(offers, func(o Offer) bool {
+		return o.Aamt >= amt && o.Lvr >= round && o.AllowsCollateral(xaid, creator, reg)
+	})
+}
+
+func orderBook(offers []Offer, allows func(Offer) bool) (book []Offer) {
+	for _, o := range offers {
+		if allows(o) {
+			book = append(book, o)
+		}
+	}
+	sort.SliceStable(book, func(i, j int) bool {
+		if book[i].FeeRate != book[j].FeeRate {
+			return book[i].FeeRate < book[j].FeeRate
+		}
+		return book[i].Aamt > book[j].Aamt
+	})
+	return
+}
+
+// NewOfferID makes a random offer id, the lsa of earn baked into the lender lsig
+func NewOfferID() (lsa [32]byte, err error) {
+	_, err = rand.Read(lsa[:])
+	return
+}
+
+// Make Jina application call to change an offer, the lsig of the previous lsa stops working
+func UpdateOffer(algodClient *algod.Client, acct crypto.Account, jina uint64, xids []uint64, aamt, lvr uint64, lsa [32]byte, contract_json string) (err error) {
+	return callAppMethod(algodClient, acct, jina, "update_offer", []interface{}{xids, aamt, lvr, lsa[:]}, contract_json)
+}
+
+// Make Jina application call to allow collateral by creator or by collection ID of the manager registry
+func SetOfferCollections(algodClient *algod.Client, acct crypto.Account, jina uint64, creators []types.Address, collections []uint64, contract_json string) (err error) {
+	return callAppMethod(algodClient, acct, jina, "set_collections", []interface{}{creators, collections}, contract_json)
+}
+
+// Make Jina application call to withdraw an offer without closing out
+func CancelOffer(algodClient *algod.Client, acct crypto.Account, jina uint64, contract_json string) (err error) {
+	return callAppMethod(algodClient, acct, jina, "cancel_offer", nil, contract_json)
+}
+
+// Register the creator of an NFT collection in the manager app
+func AddCollection(algodClient *algod.Client, acct crypto.Account, id uint64, creator types.Address, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "add_collection", []interface{}{id, creator}, contract_json)
+}
+
+// Remove a collection from the manager registry
+func RemoveCollection(algodClient *algod.Client, acct crypto.Account, id uint64, contract_json string) (err error) {
+	return callMethod(algodClient, acct, "remove_collection", []interface{}{id}, contract_json)
+}
@@ -0,0 +1,80 @@
+package jina
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+func TestOfferFromState(t *testing.T) {
+	lender := crypto.GenerateAccount().Address
+	state := map[string]models.TealValue{
+		"xids": packed(2, 7),
+		"aamt": {Type: 2, Uint: 5000},
+		"lvr":  {Type: 2, Uint: 100},
+	}
+	o, ok := OfferFromState(lender, state)
+	if !ok || o.FeeRate != DefaultFeeRate || o.MaxLTV != 0 || len(o.Xids) != 2 {
+		t.Fatalf("wrong offer %+v", o)
+	}
+	state["lfr"] = models.TealValue{Type: 2}
+	if o, _ = OfferFromState(lender, state); o.FeeRate != 0 {
+		t.Errorf("zero fee rate read as %d", o.FeeRate)
+	}
+	if _, ok = OfferFromState(lender, map[string]models.TealValue{}); ok {
+		t.Errorf("offer read from borrower state")
+	}
+}
+
+func TestOrderBook(t *testing.T) {
+	offers := []Offer{
+		{Xids: []uint64{2}, Aamt: 5000, Lvr: 100, FeeRate: 300},
+		{Xids: []uint64{2}, Aamt: 9000, Lvr: 100, FeeRate: 100},
+		{Xids: []uint64{3}, Aamt: 9000, Lvr: 100, FeeRate: 50},
+		{Xids: []uint64{2}, Aamt: 9000, Lvr: 10, FeeRate: 50},
+		{Xids: []uint64{2}, Aamt: 500, Lvr: 100, FeeRate: 50},
+	}
+	book := OrderBook(offers, 2, 1000, 50)
+	if len(book) != 2 || book[0].FeeRate != 100 || book[1].FeeRate != 300 {
+		t.Errorf("wrong order book %+v", book)
+	}
+	if fee := book[0].Fee(1000); fee != 10 {
+		t.Errorf("fee = %d", fee)
+	}
+}
+
+func TestNewOfferID(t *testing.T) {
+	a, err := NewOfferID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewOfferID()
+	if a == b || a == [32]byte{} {
+		t.Errorf("offer ids are not unique: %x %x", a, b)
+	}
+}
+
+func TestOfferCollections(t *testing.T) {
+	lender := crypto.GenerateAccount().Address
+	creator, registered, other := crypto.GenerateAccount().Address, crypto.GenerateAccount().Address, crypto.GenerateAccount().Address
+	state := map[string]models.TealValue{
+		"xids": packed(2),
+		"aamt": {Type: 2, Uint: 5000},
+		"lvr":  {Type: 2, Uint: 100},
+		"crt":  {Type: 1, Bytes: base64.StdEncoding.EncodeToString(creator[:])},
+		"cols": packed(9, 10),
+	}
+	o, _ := OfferFromState(lender, state)
+	reg := CollectionRegistry{9: registered}
+	if got := o.AcceptedCreators(reg); len(got) != 2 || got[0] != creator || got[1] != registered {
+		t.Errorf("wrong accepted creators %v", got)
+	}
+	if !o.AllowsCollateral(2, other, reg) || !o.AllowsCollateral(40, registered, reg) || o.AllowsCollateral(40, other, reg) {
+		t.Errorf("wrong collateral allowance")
+	}
+	if book := CollectionOrderBook([]Offer{o}, 40, creator, reg, 1000, 50); len(book) != 1 {
+		t.Errorf("offer allowing creator missing from order book")
+	}
+}
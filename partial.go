@@ -0,0 +1,106 @@
+package jina
+
+import (
+	"context"
+	"log"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// PartialUnitPrice is the price the liquidator pays per collateral unit under the default risk parameters
+func PartialUnitPrice(price uint64) uint64 {
+	return DefaultRiskParams.UnitPrice(price)
+}
+
+// PartialLiquidation is the fewest collateral units whose purchase brings a position back under the threshold
+type PartialLiquidation struct {
+	Position
+	Price   uint64
+	Units   uint64 // collateral clawed back
+	Payment uint64 // repaid from lamt
+}
+
+// healthyAfter mirrors the liquidator check of a position after buying units
+func (r RiskParams) healthyAfter(p Position, units, price uint64) bool {
+	repaid := units * r.UnitPrice(price)
+	if units > p.Camt || repaid > p.Lamt {
+		return false
+	}
+	left := Position{Camt: p.Camt - units, Lamt: p.Lamt - repaid}
+	return !r.Unhealthy(left, price)
+}
+
+// MinPartialLiquidation computes the minimal partial liquidation of an unhealthy position
+// under the default risk parameters
+func MinPartialLiquidation(p Position, price uint64) (l PartialLiquidation, ok bool) {
+	return DefaultRiskParams.MinPartialLiquidation(p, price)
+}
+
+// MinPartialLiquidation computes the minimal partial liquidation of a position unhealthy under r.
+// ok is false when the position is healthy or only a full liquidation can restore it.
+func (r RiskParams) MinPartialLiquidation(p Position, price uint64) (l PartialLiquidation, ok bool) {
+	unit := r.UnitPrice(price)
+	// buying below the threshold price never improves health
+	if unit*100 <= price*r.Threshold || !r.Unhealthy(p, price) {
+		return
+	}
+	// lamt - k*unit <= (camt-k)*price*threshold/100, solved for k before rounding
+	units := (p.Lamt*100 - p.Camt*price*r.Threshold) / (unit*100 - price*r.Threshold)
+	if units == 0 {
+		units = 1
+	}
+	for units > 1 && r.healthyAfter(p, units-1, price) {
+		units--
+	}
+	for ; units <= p.Camt; units++ {
+		if r.healthyAfter(p, units, price) {
+			return PartialLiquidation{Position: p, Price: price, Units: units, Payment: units * unit}, true
+		}
+	}
+	return
+}
+
+// Make liquidator application call buying the minimal collateral units of an unhealthy loan
+func PartialLiquidate(algodClient *algod.Client, acct crypto.Account, liquidatee, receiver types.Address, mng, jina, lqt, xaid, pay, amt uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	// payment, forward payment, reduce loan in jina and clawback
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(5 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           lqt,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	txParams.Fee = 0
+	txn, _ := future.MakeAssetTransferTxn(acct.Address.String(), crypto.GetApplicationAddress(lqt).String(), amt, nil, txParams, "", pay)
+	stxn := future.TransactionWithSigner{Txn: txn, Signer: signer}
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "partial_liquidate"), []interface{}{stxn, liquidatee, receiver, xaid, pay, mng, jina}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+	atc, err = withAccounts(atc, 1, crypto.GetApplicationAddress(jina))
+	if err != nil {
+		return
+	}
+
+	_, err = atc.Execute(algodClient, context.Background(), 2)
+	return
+}
@@ -0,0 +1,20 @@
+package jina
+
+import "testing"
+
+func TestMinPartialLiquidation(t *testing.T) {
+	p := Position{AssetID: 2, Camt: 1000, Lamt: 46000}
+	if _, ok := MinPartialLiquidation(p, 60); ok {
+		t.Errorf("healthy position planned for partial liquidation")
+	}
+	l, ok := MinPartialLiquidation(p, 50)
+	if !ok || l.Units != 500 || l.Payment != 23500 {
+		t.Fatalf("wrong partial liquidation %+v %v", l, ok)
+	}
+	if !DefaultRiskParams.healthyAfter(p, l.Units, 50) || DefaultRiskParams.healthyAfter(p, l.Units-1, 50) {
+		t.Errorf("partial liquidation of %d units is not minimal", l.Units)
+	}
+	if _, ok = MinPartialLiquidation(Position{Camt: 1, Lamt: 46}, 50); ok {
+		t.Errorf("single unit position planned for partial liquidation")
+	}
+}
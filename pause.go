@@ -0,0 +1,48 @@
+package jina
+
+import (
+	"errors"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+// Paused reports whether the manager has halted borrowing and liquidation
+func Paused(algodClient *algod.Client, mng uint64) (paused bool, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	return state["paused"].Uint != 0, nil
+}
+
+// LoanRound mirrors the contracts' loan_round: the round loans accrue interest and mature by at round,
+// frozen while paused and less the rounds of past pauses
+func LoanRound(algodClient *algod.Client, mng, round uint64) (uint64, error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return 0, err
+	}
+	return loanRound(state["paused"].Uint, state["idle"].Uint, round), nil
+}
+
+func loanRound(paused, idle, round uint64) uint64 {
+	if paused != 0 {
+		round = paused
+	}
+	return round - idle
+}
+
+// Make manager application call halting borrow, change_collateral and liquidation in every market.
+// Repay and claim stay open, loans stop accruing interest and maturing. The reason is recorded in the transaction note.
+func Pause(algodClient *algod.Client, acct crypto.Account, reason string, contract_json string) (err error) {
+	if reason == "" {
+		return errors.New("pause needs a reason")
+	}
+	return callMethodWithNote(algodClient, acct, "pause", nil, []byte("pause: "+reason), contract_json)
+}
+
+// Make manager application call resuming borrowing and liquidation, with the reason in the note
+func Unpause(algodClient *algod.Client, acct crypto.Account, reason string, contract_json string) (err error) {
+	return callMethodWithNote(algodClient, acct, "unpause", nil, []byte("unpause: "+reason), contract_json)
+}
@@ -0,0 +1,26 @@
+package jina
+
+import (
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+func TestPauseNeedsReason(t *testing.T) {
+	if err := Pause(nil, crypto.Account{}, "", "./abi/manager.json"); err == nil {
+		t.Errorf("paused without a reason")
+	}
+}
+
+func TestLoanRound(t *testing.T) {
+	if got := loanRound(0, 0, 500); got != 500 {
+		t.Errorf("loan round %d before any pause, want 500", got)
+	}
+	// paused at 400 after 100 rounds of past pauses
+	if got := loanRound(400, 100, 500); got != 300 {
+		t.Errorf("loan round %d while paused, want 300", got)
+	}
+	if got := loanRound(0, 200, 500); got != 300 {
+		t.Errorf("loan round %d after unpausing, want 300", got)
+	}
+}
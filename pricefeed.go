@@ -0,0 +1,516 @@
+package jina
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// PriceQuote is a price of one unit of a collateral asset in USDCa micro units
+type PriceQuote struct {
+	AssetID uint64    `json:"asset"`
+	Price   uint64    `json:"price"`
+	Time    time.Time `json:"time"`
+	Source  string    `json:"-"`
+}
+
+// PriceSource is a pluggable provider of NFT floor or appraisal prices
+type PriceSource interface {
+	Name() string
+	Fetch(ctx context.Context, assets []uint64) ([]PriceQuote, error)
+}
+
+// FileSource reads quotes from a local JSON or CSV file.
+// JSON files hold an array of quotes, CSV files hold rows of asset,price[,time]
+// where time is RFC3339 or unix seconds. Quotes without time get the file's mod time.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Name() string { return "file:" + s.Path }
+
+func (s FileSource) Fetch(ctx context.Context, assets []uint64) (quotes []PriceQuote, err error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if strings.EqualFold(filepath.Ext(s.Path), ".csv") {
+		quotes, err = parseCSVQuotes(f)
+	} else {
+		quotes, err = parseJSONQuotes(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return stampQuotes(quotes, assets, s.Name(), fi.ModTime()), nil
+}
+
+// HTTPSource fetches quotes from a JSON endpoint.
+// If URL contains {asset} it is requested once per asset and the price is read
+// from the dotted PricePath (and optional TimePath) of the response,
+// otherwise the response is an array of quotes as in FileSource.
+type HTTPSource struct {
+	URL       string
+	PricePath string
+	TimePath  string
+	Client    *http.Client
+}
+
+func (s HTTPSource) Name() string { return "http:" + s.URL }
+
+func (s HTTPSource) Fetch(ctx context.Context, assets []uint64) (quotes []PriceQuote, err error) {
+	now := time.Now()
+	if !strings.Contains(s.URL, "{asset}") {
+		body, err := s.get(ctx, s.URL)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		quotes, err = parseJSONQuotes(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.URL, err)
+		}
+		return stampQuotes(quotes, assets, s.Name(), now), nil
+	}
+	for _, asset := range assets {
+		url := strings.ReplaceAll(s.URL, "{asset}", strconv.FormatUint(asset, 10))
+		q, err := s.fetchOne(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		q.AssetID = asset
+		quotes = append(quotes, q)
+	}
+	return stampQuotes(quotes, assets, s.Name(), now), nil
+}
+
+func (s HTTPSource) fetchOne(ctx context.Context, url string) (q PriceQuote, err error) {
+	body, err := s.get(ctx, url)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	var doc interface{}
+	if err = json.NewDecoder(body).Decode(&doc); err != nil {
+		return q, fmt.Errorf("%s: %v", url, err)
+	}
+	price, err := jsonPath(doc, s.PricePath)
+	if err != nil {
+		return q, fmt.Errorf("%s: %v", url, err)
+	}
+	if q.Price, err = parseUint(price); err != nil {
+		return q, fmt.Errorf("%s: price %v", url, err)
+	}
+	if s.TimePath != "" {
+		t, err := jsonPath(doc, s.TimePath)
+		if err != nil {
+			return q, fmt.Errorf("%s: %v", url, err)
+		}
+		if q.Time, err = parseTime(fmt.Sprint(t)); err != nil {
+			return q, fmt.Errorf("%s: time %v", url, err)
+		}
+	}
+	return
+}
+
+func (s HTTPSource) get(ctx context.Context, url string) (io.ReadCloser, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// walk a dotted path ("data.floor.price") through decoded JSON
+func jsonPath(doc interface{}, path string) (interface{}, error) {
+	for _, p := range strings.Split(path, ".") {
+		if p == "" {
+			continue
+		}
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no object at %q", p)
+		}
+		if doc, ok = m[p]; !ok {
+			return nil, fmt.Errorf("no field %q", p)
+		}
+	}
+	return doc, nil
+}
+
+func parseJSONQuotes(r io.Reader) (quotes []PriceQuote, err error) {
+	err = json.NewDecoder(r).Decode(&quotes)
+	return
+}
+
+func parseCSVQuotes(r io.Reader) (quotes []PriceQuote, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return
+	}
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("line %d: want asset,price[,time]", i+1)
+		}
+		asset, err := strconv.ParseUint(row[0], 10, 64)
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: asset %v", i+1, err)
+		}
+		q := PriceQuote{AssetID: asset}
+		if q.Price, err = parseUint(row[1]); err != nil {
+			return nil, fmt.Errorf("line %d: price %v", i+1, err)
+		}
+		if len(row) > 2 && row[2] != "" {
+			if q.Time, err = parseTime(row[2]); err != nil {
+				return nil, fmt.Errorf("line %d: time %v", i+1, err)
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	return
+}
+
+func parseUint(v interface{}) (uint64, error) {
+	switch p := v.(type) {
+	case float64:
+		if p < 0 {
+			return 0, fmt.Errorf("negative value %v", p)
+		}
+		return uint64(p), nil
+	case string:
+		return strconv.ParseUint(strings.TrimSpace(p), 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected value %v", v)
+}
+
+func parseTime(v string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// keep quotes of requested assets, naming their source and filling in missing times
+func stampQuotes(quotes []PriceQuote, assets []uint64, source string, t time.Time) (out []PriceQuote) {
+	for _, q := range quotes {
+		if len(assets) > 0 && !containsUint64(assets, q.AssetID) {
+			continue
+		}
+		if q.Time.IsZero() {
+			q.Time = t
+		}
+		q.Source = source
+		out = append(out, q)
+	}
+	return
+}
+
+func containsUint64(vals []uint64, v uint64) bool {
+	for _, x := range vals {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedConfig holds the limits applied by the price feed.
+// Percentages are expressed in basis points (1% == 100).
+type FeedConfig struct {
+	Assets       []uint64
+	Interval     time.Duration // time between polls
+	MaxAge       time.Duration // quotes older than this are stale
+	MinSources   int           // fresh quotes needed to aggregate
+	OutlierBps   uint64        // quotes further than this from the median are rejected
+	MaxDeviation uint64        // largest move published per poll, bigger moves are clamped
+	Threshold    uint64        // aggregate must move at least this much to be published
+}
+
+// AggregatePrice drops stale quotes, takes the median, rejects outliers and
+// returns the median of the remaining quotes of one asset
+func AggregatePrice(quotes []PriceQuote, cfg FeedConfig, now time.Time) (price uint64, err error) {
+	var fresh []uint64
+	for _, q := range quotes {
+		if cfg.MaxAge > 0 && now.Sub(q.Time) > cfg.MaxAge {
+			continue
+		}
+		fresh = append(fresh, q.Price)
+	}
+	min := cfg.MinSources
+	if min < 1 {
+		min = 1
+	}
+	if len(fresh) < min {
+		return 0, fmt.Errorf("%d fresh quotes, need %d", len(fresh), min)
+	}
+	price = median(fresh)
+	if cfg.OutlierBps == 0 {
+		return
+	}
+	var kept []uint64
+	for _, p := range fresh {
+		if deviationBps(p, price) <= cfg.OutlierBps {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) < min {
+		return 0, fmt.Errorf("%d quotes after outlier rejection, need %d", len(kept), min)
+	}
+	return median(kept), nil
+}
+
+func median(vals []uint64) uint64 {
+	s := append([]uint64(nil), vals...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	// average without overflowing
+	return s[n/2-1]/2 + s[n/2]/2 + (s[n/2-1]%2+s[n/2]%2)/2
+}
+
+// deviation of p from ref in basis points
+func deviationBps(p, ref uint64) uint64 {
+	if ref == 0 {
+		if p == 0 {
+			return 0
+		}
+		return ^uint64(0)
+	}
+	d := p - ref
+	if p < ref {
+		d = ref - p
+	}
+	return d * 10000 / ref
+}
+
+// ShouldPublish checks the aggregated price against the last published price
+// and returns the price to publish, false when the move is below Threshold.
+// Moves over MaxDeviation are clamped to it, so a lasting move is reached over
+// several polls while a single bad aggregate can only move the price so far.
+func ShouldPublish(price, last uint64, cfg FeedConfig) (uint64, bool) {
+	if last == 0 {
+		return price, true
+	}
+	dev := deviationBps(price, last)
+	if dev < cfg.Threshold || price == last {
+		return last, false
+	}
+	if cfg.MaxDeviation > 0 && dev > cfg.MaxDeviation {
+		step := last/10000*cfg.MaxDeviation + last%10000*cfg.MaxDeviation/10000
+		if price > last {
+			price = last + step
+		} else {
+			price = last - step
+		}
+	}
+	return price, true
+}
+
+// PriceFeed polls its sources and publishes aggregated prices
+type PriceFeed struct {
+	Sources []PriceSource
+	Config  FeedConfig
+	// Last returns the last published price of an asset, 0 if none
+	Last func(assetID uint64) (uint64, error)
+	// Publish writes a new price on-chain
+	Publish func(assetID, price uint64) error
+}
+
+// NewPriceFeed makes a feed reading and publishing prices of the manager app mng with acct
+func NewPriceFeed(algodClient *algod.Client, acct crypto.Account, mng uint64, contract_json string, cfg FeedConfig, sources ...PriceSource) *PriceFeed {
+	return &PriceFeed{
+		Sources: sources,
+		Config:  cfg,
+		Last: func(assetID uint64) (uint64, error) {
+			price, _, err := ReadPrice(algodClient, mng, assetID)
+			return price, err
+		},
+		Publish: func(assetID, price uint64) error {
+			return PublishPrice(algodClient, acct, mng, assetID, price, contract_json)
+		},
+	}
+}
+
+// Run polls every Interval until ctx is done
+func (pf *PriceFeed) Run(ctx context.Context) error {
+	interval := pf.Config.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pf.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches all sources once and publishes the assets whose price changed enough
+func (pf *PriceFeed) Poll(ctx context.Context) (published map[uint64]uint64) {
+	published = make(map[uint64]uint64)
+	byAsset := make(map[uint64][]PriceQuote)
+	for _, src := range pf.Sources {
+		quotes, err := src.Fetch(ctx, pf.Config.Assets)
+		if err != nil {
+			log.Printf("price source %s: %v", src.Name(), err)
+			continue
+		}
+		for _, q := range quotes {
+			byAsset[q.AssetID] = append(byAsset[q.AssetID], q)
+		}
+	}
+	now := time.Now()
+	for _, asset := range pf.Config.Assets {
+		price, err := AggregatePrice(byAsset[asset], pf.Config, now)
+		if err != nil {
+			log.Printf("asset %d: %v", asset, err)
+			continue
+		}
+		last, err := pf.Last(asset)
+		if err != nil {
+			log.Printf("asset %d: reading last price: %v", asset, err)
+			continue
+		}
+		aggregate := price
+		price, ok := ShouldPublish(aggregate, last, pf.Config)
+		if !ok {
+			continue
+		}
+		if price != aggregate {
+			log.Printf("asset %d: clamped %d to %d", asset, aggregate, price)
+		}
+		if err = pf.Publish(asset, price); err != nil {
+			log.Printf("asset %d: publishing %d: %v", asset, price, err)
+			continue
+		}
+		log.Printf("asset %d: published %d (was %d)", asset, price, last)
+		published[asset] = price
+	}
+	return
+}
+
+// ReadPrice reads the price and update round of an asset from the manager app
+func ReadPrice(algodClient *algod.Client, mng, assetID uint64) (price, round uint64, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], assetID)
+	v, ok := state[string(key[:])]
+	if !ok {
+		return
+	}
+	b := stateBytes(v)
+	if len(b) < 16 {
+		return 0, 0, fmt.Errorf("malformed price of asset %d", assetID)
+	}
+	return binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:16]), nil
+}
+
+// Make manager application call to publish oracle price of a collateral asset
+func PublishPrice(algodClient *algod.Client, acct crypto.Account, mng, assetID, price uint64, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get suggested params: %v", err)
+	}
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           mng,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "price"), []interface{}{assetID, price}))
+	if err != nil {
+		return fmt.Errorf("failed to add price call: %v", err)
+	}
+
+	_, err = atc.Execute(algodClient, context.Background(), 2)
+	return
+}
+
+// LoadPriceSources reads a JSON list of sources, e.g.
+// [{"file":"./prices.csv"},{"url":"http://localhost:8080/floor/{asset}","price":"floor"}]
+func LoadPriceSources(file string) (sources []PriceSource, err error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	var specs []struct {
+		File  string `json:"file"`
+		URL   string `json:"url"`
+		Price string `json:"price"`
+		Time  string `json:"time"`
+	}
+	if err = json.Unmarshal(b, &specs); err != nil {
+		return
+	}
+	for i, s := range specs {
+		switch {
+		case s.File != "":
+			sources = append(sources, FileSource{Path: s.File})
+		case s.URL != "":
+			sources = append(sources, HTTPSource{URL: s.URL, PricePath: s.Price, TimePath: s.Time})
+		default:
+			return nil, fmt.Errorf("source %d: needs file or url", i)
+		}
+	}
+	return
+}
@@ -0,0 +1,122 @@
+package jina
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAggregatePrice(t *testing.T) {
+	now := time.Now()
+	cfg := FeedConfig{MaxAge: time.Hour, MinSources: 2, OutlierBps: 1000}
+	quotes := []PriceQuote{
+		{AssetID: 2, Price: 100, Time: now},
+		{AssetID: 2, Price: 104, Time: now},
+		{AssetID: 2, Price: 98, Time: now},
+		{AssetID: 2, Price: 500, Time: now},                   // outlier
+		{AssetID: 2, Price: 1, Time: now.Add(-2 * time.Hour)}, // stale
+	}
+	price, err := AggregatePrice(quotes, cfg, now)
+	if err != nil {
+		t.Fatalf("expecting no errors, got %s", err)
+	}
+	if price != 100 {
+		t.Errorf("expected median 100, got %d", price)
+	}
+
+	_, err = AggregatePrice(quotes[3:], cfg, now)
+	if err == nil {
+		t.Errorf("expected too few fresh quotes error")
+	}
+}
+
+func TestShouldPublish(t *testing.T) {
+	cfg := FeedConfig{MaxDeviation: 2000, Threshold: 100}
+	cases := []struct {
+		price, last, want uint64
+		ok                bool
+	}{
+		{100, 0, 100, true},
+		{100, 100, 100, false},
+		{1005, 1000, 1000, false},
+		{1010, 1000, 1010, true},
+		{1300, 1000, 1200, true},
+		{500, 1000, 800, true},
+	}
+	for _, c := range cases {
+		price, ok := ShouldPublish(c.price, c.last, cfg)
+		if ok != c.ok || price != c.want {
+			t.Errorf("ShouldPublish(%d, %d) = %d, %v", c.price, c.last, price, ok)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "prices.csv")
+	os.WriteFile(csvFile, []byte("asset,price,time\n2,50000000,1650000000\n3,7,\n"), 0644)
+	jsonFile := filepath.Join(dir, "prices.json")
+	os.WriteFile(jsonFile, []byte(`[{"asset":2,"price":49000000},{"asset":9,"price":1}]`), 0644)
+
+	quotes, err := FileSource{Path: csvFile}.Fetch(context.Background(), []uint64{2, 3})
+	if err != nil {
+		t.Fatalf("expecting no errors, got %s", err)
+	}
+	if len(quotes) != 2 || quotes[0].Price != 50000000 || quotes[0].Time.Unix() != 1650000000 || quotes[1].Time.IsZero() {
+		t.Errorf("wrong csv quotes %+v", quotes)
+	}
+
+	quotes, err = FileSource{Path: jsonFile}.Fetch(context.Background(), []uint64{2})
+	if err != nil {
+		t.Fatalf("expecting no errors, got %s", err)
+	}
+	if len(quotes) != 1 || quotes[0].Price != 49000000 {
+		t.Errorf("wrong json quotes %+v", quotes)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":{"floor":%q}}`, r.URL.Path[len("/floor/"):]+"000")
+	}))
+	defer srv.Close()
+
+	src := HTTPSource{URL: srv.URL + "/floor/{asset}", PricePath: "data.floor"}
+	quotes, err := src.Fetch(context.Background(), []uint64{2, 3})
+	if err != nil {
+		t.Fatalf("expecting no errors, got %s", err)
+	}
+	if len(quotes) != 2 || quotes[0].Price != 2000 || quotes[1].Price != 3000 {
+		t.Errorf("wrong http quotes %+v", quotes)
+	}
+}
+
+func TestPriceFeedPoll(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prices.json")
+	os.WriteFile(file, []byte(`[{"asset":2,"price":1100},{"asset":3,"price":1001},{"asset":4,"price":2000}]`), 0644)
+
+	last := map[uint64]uint64{2: 1000, 3: 1000, 4: 1000}
+	pf := &PriceFeed{
+		Sources: []PriceSource{FileSource{Path: file}},
+		Config:  FeedConfig{Assets: []uint64{2, 3, 4}, Threshold: 100, MaxDeviation: 2000},
+		Last:    func(a uint64) (uint64, error) { return last[a], nil },
+		Publish: func(a, p uint64) error { last[a] = p; return nil },
+	}
+	published := pf.Poll(context.Background())
+	if len(published) != 2 || published[2] != 1100 || published[4] != 1200 || last[3] != 1000 {
+		t.Errorf("wrong publishes %v", published)
+	}
+	// a lasting move is reached over several polls
+	for i := 0; i < 4; i++ {
+		pf.Poll(context.Background())
+	}
+	if last[4] != 2000 {
+		t.Errorf("price of 4 is %d after repeated polls", last[4])
+	}
+}
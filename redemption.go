@@ -0,0 +1,213 @@
+package jina
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// MinRedemption is the least JUSD jina queues, so dust cannot fill the queue
+const MinRedemption = 1000000
+
+// Redemption is JUSD queued in jina for USDCa, keyed "q"||sequence in jina global state
+type Redemption struct {
+	Seq      uint64
+	Claimant types.Address
+	Amount   uint64 // JUSD not yet paid
+}
+
+// RedemptionQueue is jina's queue of redemptions waiting for repayments
+type RedemptionQueue struct {
+	Head        uint64 // next sequence to pay
+	Tail        uint64 // next sequence to queue
+	Queued      uint64 // JUSD queued
+	Redemptions []Redemption
+}
+
+// Reserves compares the JUSD outstanding with the USDCa backing it in a market
+type Reserves struct {
+	Outstanding uint64 // JUSD held outside jina, with the manager's treasury and insurance JUSD
+	Queued      uint64 // JUSD waiting in the redemption queue
+	Held        uint64 // USDCa held by jina
+}
+
+func decodeRedemption(key string, value []byte) (r Redemption, ok bool) {
+	if len(key) != 9 || key[0] != 'q' || len(value) != 40 {
+		return
+	}
+	r.Seq = binary.BigEndian.Uint64([]byte(key[1:]))
+	copy(r.Claimant[:], value)
+	r.Amount = binary.BigEndian.Uint64(value[32:])
+	return r, true
+}
+
+// ReadRedemptionQueue reads the redemption queue from jina global state, in payment order
+func ReadRedemptionQueue(algodClient *algod.Client, jina uint64) (q RedemptionQueue, err error) {
+	state, err := globalState(algodClient, jina)
+	if err != nil {
+		return
+	}
+	return redemptionQueue(state), nil
+}
+
+// redemptionQueue decodes the redemption queue of jina global state
+func redemptionQueue(state map[string]models.TealValue) (q RedemptionQueue) {
+	q.Head = state["qh"].Uint
+	q.Tail = state["qt"].Uint
+	q.Queued = state["qamt"].Uint
+	for k, v := range state {
+		if r, ok := decodeRedemption(k, stateBytes(v)); ok {
+			q.Redemptions = append(q.Redemptions, r)
+		}
+	}
+	sort.Slice(q.Redemptions, func(i, j int) bool { return q.Redemptions[i].Seq < q.Redemptions[j].Seq })
+	return
+}
+
+// Position returns the place of a redemption in the queue, 0 for the next paid, and the JUSD queued ahead of it
+func (q RedemptionQueue) Position(seq uint64) (place int, ahead uint64, ok bool) {
+	for i, r := range q.Redemptions {
+		if r.Seq == seq {
+			return i, ahead, true
+		}
+		ahead += r.Amount
+	}
+	return
+}
+
+// Claimable is the JUSD that can be claimed at once, ahead of nothing queued
+func (r Reserves) Claimable() uint64 {
+	if r.Held <= r.Queued {
+		return 0
+	}
+	return r.Held - r.Queued
+}
+
+// Redeemable is the JUSD jina write_off shares a loss over, outstanding or queued
+func (r Reserves) Redeemable() uint64 {
+	return r.Outstanding + r.Queued
+}
+
+// jusdOutstanding reads the JUSD held outside jina. The manager's JUSD counts,
+// its treasury is paid out to JNA holders and its insurance reserve covers shortfalls
+func jusdOutstanding(algodClient *algod.Client, m Market) (amt uint64, err error) {
+	asset, err := algodClient.GetAssetByID(m.IOU).Do(context.Background())
+	if err != nil {
+		return
+	}
+	holding, err := algodClient.AccountAssetInformation(crypto.GetApplicationAddress(m.Jina).String(), m.IOU).Do(context.Background())
+	if err != nil {
+		return
+	}
+	return asset.Params.Total - holding.AssetHolding.Amount, nil
+}
+
+// ReadReserves reads JUSD outstanding and USDCa held by jina in a market
+func ReadReserves(algodClient *algod.Client, m Market) (r Reserves, err error) {
+	q, err := ReadRedemptionQueue(algodClient, m.Jina)
+	if err != nil {
+		return
+	}
+	r.Queued = q.Queued
+	r.Outstanding, err = jusdOutstanding(algodClient, m)
+	if err != nil {
+		return
+	}
+	holding, err := algodClient.AccountAssetInformation(crypto.GetApplicationAddress(m.Jina).String(), m.Stablecoin).Do(context.Background())
+	if err != nil {
+		return
+	}
+	r.Held = holding.AssetHolding.Amount
+	return
+}
+
+// Make jina application call queueing amt JUSD for redemption, returning its sequence
+func Enqueue(algodClient *algod.Client, acct crypto.Account, mng, jina, amt, usdc, jusd uint64, contract_json string) (seq uint64, err error) {
+	if amt < MinRedemption {
+		return 0, fmt.Errorf("redemption of %d JUSD, less than %d", amt, MinRedemption)
+	}
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           jina,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	txn, _ := future.MakeAssetTransferTxn(acct.Address.String(), crypto.GetApplicationAddress(jina).String(), amt, nil, txParams, "", jusd)
+	stxn := future.TransactionWithSigner{Txn: txn, Signer: signer}
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, "enqueue"), []interface{}{stxn, usdc, mng}))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	ret := debugAppCall(algodClient, atc, "./dryrun/enqueue.msgp", "./dryrun/response/enqueue.json")
+	seq = ret[0].ReturnValue.(uint64)
+	return
+}
+
+// Make jina application call paying the redemption at the head of the queue, any account can.
+// A claimant that cannot receive USDCa is moved to the back of the queue instead
+func ProcessQueue(algodClient *algod.Client, acct crypto.Account, claimant types.Address, mng, jina, usdc uint64, contract_json string) (err error) {
+	return callQueue(algodClient, acct, jina, "process_queue", []interface{}{claimant, usdc, mng}, contract_json)
+}
+
+// Make jina application call cancelling a queued redemption of acct, returning the JUSD not yet paid
+func CancelClaim(algodClient *algod.Client, acct crypto.Account, mng, jina, seq, jusd uint64, contract_json string) (err error) {
+	return callQueue(algodClient, acct, jina, "cancel_claim", []interface{}{seq, jusd, mng}, contract_json)
+}
+
+// callQueue makes a redemption queue call paying for its transfer
+func callQueue(algodClient *algod.Client, acct crypto.Account, jina uint64, method string, args []interface{}, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(2 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           jina,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	var atc future.AtomicTransactionComposer
+	err = atc.AddMethodCall(combine(mcp, getMethod(contract, method), args))
+	if err != nil {
+		log.Fatalf("Failed to AddMethodCall: %+v", err)
+	}
+
+	debugAppCall(algodClient, atc, "./dryrun/"+method+".msgp", "./dryrun/response/"+method+".json")
+	return
+}
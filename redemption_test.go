@@ -0,0 +1,42 @@
+package jina
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestDecodeRedemption(t *testing.T) {
+	key := make([]byte, 9)
+	key[0] = 'q'
+	binary.BigEndian.PutUint64(key[1:], 4)
+	value := make([]byte, 40)
+	value[0] = 7
+	binary.BigEndian.PutUint64(value[32:], 2500)
+
+	r, ok := decodeRedemption(string(key), value)
+	if !ok || r.Seq != 4 || r.Claimant != (types.Address{7}) || r.Amount != 2500 {
+		t.Fatalf("wrong redemption %+v", r)
+	}
+	if _, ok = decodeRedemption("qh", value); ok {
+		t.Errorf("decoded redemption from counter")
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	q := RedemptionQueue{Redemptions: []Redemption{{Seq: 2, Amount: 100}, {Seq: 4, Amount: 300}, {Seq: 5, Amount: 50}}}
+	place, ahead, ok := q.Position(5)
+	if !ok || place != 2 || ahead != 400 {
+		t.Errorf("position of 5 = %d, %d, %v", place, ahead, ok)
+	}
+	if _, _, ok = q.Position(3); ok {
+		t.Errorf("found cancelled redemption")
+	}
+	if c := (Reserves{Held: 1000, Queued: 400}).Claimable(); c != 600 {
+		t.Errorf("claimable = %d", c)
+	}
+	if r := (Reserves{Outstanding: 1000, Queued: 400}).Redeemable(); r != 1400 {
+		t.Errorf("redeemable = %d", r)
+	}
+}
@@ -0,0 +1,298 @@
+package jina
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// VerifyOfferLsig checks an uploaded lsig against the lender's offer in jina local state.
+// The lsig args are unsigned, so the terms they claim are only trusted when they hash to the offer's lsa
+// and program, the template compiled for them, is the signed program. The terms must match its aamt and lvr
+func VerifyOfferLsig(lsa crypto.LogicSigAccount, o Offer, program []byte, usdc, jina, round uint64) error {
+	r := InspectLenderLsig(lsa, o.Lender, program, usdc, jina, round)
+	if !r.Safe() {
+		return fmt.Errorf("unsafe lsig: %s", strings.Join(r.Warnings, "; "))
+	}
+	if id := r.Terms.OfferID(o.Lender); !bytes.Equal(id[:], o.Lsa) {
+		return fmt.Errorf("lsig terms are not the terms of the offer")
+	}
+	if r.Terms.LastValid != o.Lvr {
+		return fmt.Errorf("lsig expires at round %d, offer at %d", r.Terms.LastValid, o.Lvr)
+	}
+	if r.Terms.Amount < o.Aamt {
+		return fmt.Errorf("lsig lends %d, less than the %d offered", r.Terms.Amount, o.Aamt)
+	}
+	return nil
+}
+
+// LsigRegistry serves lender lsigs by lender address, verified against their jina offers.
+// Lenders POST the codec JSON to /lsigs/<address> and borrowers GET it back,
+// GET /lsigs lists the lenders with their terms.
+type LsigRegistry struct {
+	USDCa uint64 // stablecoin of the market, any when zero
+	Jina  uint64
+	Dir   string // keeps uploaded lsigs across restarts when set
+
+	// Offer returns the lender's offer in jina local state, without lsa when there is none
+	Offer func(lender types.Address) (Offer, error)
+	// Program returns the lender lsig template compiled for the terms an lsig claims
+	Program func(t LenderTemplate) ([]byte, error)
+	// Round returns the current round
+	Round func() (uint64, error)
+
+	mu    sync.Mutex
+	lsigs map[types.Address]crypto.LogicSigAccount
+}
+
+// RegisteredLsig is a lender listed by the registry
+type RegisteredLsig struct {
+	Lender types.Address `json:"lender"`
+	Terms  LenderTerms   `json:"terms"`
+}
+
+// NewLsigRegistry makes a registry for a market, loading the lsigs kept in dir
+func NewLsigRegistry(algodClient *algod.Client, usdc, jina uint64, osTealFile, dir string) (*LsigRegistry, error) {
+	r := &LsigRegistry{USDCa: usdc, Jina: jina, Dir: dir}
+	r.Offer = func(lender types.Address) (o Offer, err error) {
+		offers, err := Offers(algodClient, jina, []types.Address{lender})
+		if err != nil || len(offers) == 0 {
+			return Offer{Lender: lender}, err
+		}
+		return offers[0], nil
+	}
+	r.Program = func(t LenderTemplate) ([]byte, error) {
+		return ExpectedLenderProgram(algodClient, osTealFile, t)
+	}
+	r.Round = func() (uint64, error) {
+		status, err := algodClient.Status().Do(context.Background())
+		return status.LastRound, err
+	}
+	return r, r.load()
+}
+
+func (r *LsigRegistry) load() error {
+	r.lsigs = make(map[types.Address]crypto.LogicSigAccount)
+	if r.Dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(r.Dir, "*.codec"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		lender, err := types.DecodeAddress(strings.TrimSuffix(filepath.Base(f), ".codec"))
+		if err != nil {
+			continue
+		}
+		lsa, err := ReadLsigFile(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f, err)
+		}
+		r.lsigs[lender] = lsa
+	}
+	return nil
+}
+
+// Register verifies a lender's lsig against its current offer and keeps it, replacing the previous one
+func (r *LsigRegistry) Register(lender types.Address, lsa crypto.LogicSigAccount) error {
+	invalid, err := r.verify(lender, lsa)
+	if err != nil {
+		return err
+	}
+	if invalid != nil {
+		return invalid
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lsigs == nil {
+		r.lsigs = make(map[types.Address]crypto.LogicSigAccount)
+	}
+	r.lsigs[lender] = lsa
+	if r.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(r.Dir, 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(lsa, "", "")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(r.Dir, lender.String()+".codec"), b, 0600)
+}
+
+// Lookup returns a lender's lsig while it still matches its offer,
+// an lsig of an updated, cancelled or expired offer is dropped and ErrNoLsig returned
+func (r *LsigRegistry) Lookup(lender types.Address) (lsa crypto.LogicSigAccount, err error) {
+	r.mu.Lock()
+	lsa, ok := r.lsigs[lender]
+	r.mu.Unlock()
+	if !ok {
+		return lsa, ErrNoLsig
+	}
+	invalid, err := r.verify(lender, lsa)
+	if err != nil {
+		return
+	}
+	if invalid != nil {
+		r.drop(lender)
+		log.Printf("dropped lsig of %s: %v", lender, invalid)
+		return lsa, ErrNoLsig
+	}
+	return
+}
+
+// ErrNoLsig is returned by Lookup for lenders without a valid lsig
+var ErrNoLsig = fmt.Errorf("no lsig")
+
+func (r *LsigRegistry) drop(lender types.Address) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.lsigs, lender)
+	if r.Dir != "" {
+		os.Remove(filepath.Join(r.Dir, lender.String()+".codec"))
+	}
+}
+
+// verify returns why the lsig does not match the lender's offer in invalid,
+// and errors reading the offer or compiling the template in err
+func (r *LsigRegistry) verify(lender types.Address, lsa crypto.LogicSigAccount) (invalid, err error) {
+	o, err := r.Offer(lender)
+	if err != nil {
+		return
+	}
+	if len(o.Lsa) != 32 {
+		return fmt.Errorf("%s has no offer in jina %d", lender, r.Jina), nil
+	}
+	terms, err := DecodeLenderArgs(lsa.Lsig.Args)
+	if err != nil {
+		return err, nil
+	}
+	program, err := r.Program(terms.Template(lender))
+	if err != nil {
+		return
+	}
+	round, err := r.Round()
+	if err != nil {
+		return
+	}
+	return VerifyOfferLsig(lsa, o, program, r.USDCa, r.Jina, round), nil
+}
+
+// List returns the registered lenders with their terms, without verifying them again
+func (r *LsigRegistry) List() (list []RegisteredLsig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for lender, lsa := range r.lsigs {
+		terms, _ := DecodeLenderArgs(lsa.Lsig.Args)
+		list = append(list, RegisteredLsig{Lender: lender, Terms: terms})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Lender.String() < list[j].Lender.String() })
+	return
+}
+
+func (r *LsigRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/lsigs"), "/")
+	if path == "" {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, r.List())
+		return
+	}
+	lender, err := types.DecodeAddress(path)
+	if err != nil {
+		http.Error(w, "bad lender address", http.StatusBadRequest)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		lsa, err := r.Lookup(lender)
+		if err == ErrNoLsig {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(w, lsa)
+	case http.MethodPost, http.MethodPut:
+		var lsa crypto.LogicSigAccount
+		if err := json.NewDecoder(io.LimitReader(req.Body, 1<<16)).Decode(&lsa); err != nil {
+			http.Error(w, "bad lsig: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := r.Register(lender, lsa); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// UploadLsig sends a lender's lsig to a registry
+func UploadLsig(ctx context.Context, registry string, lender types.Address, lsa crypto.LogicSigAccount) error {
+	b, err := json.Marshal(lsa)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(registry, "/") + "/lsigs/" + lender.String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// FetchLsig gets a lender's lsig from a registry
+func FetchLsig(ctx context.Context, registry string, lender types.Address) (lsa crypto.LogicSigAccount, err error) {
+	url := strings.TrimSuffix(registry, "/") + "/lsigs/" + lender.String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return lsa, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&lsa)
+	return
+}
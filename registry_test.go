@@ -0,0 +1,97 @@
+package jina
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestLsigRegistry(t *testing.T) {
+	acct, terms := testLender(), testTerms
+	lsa := terms.OfferID(acct.Address)
+	l := testLenderLsig(t, terms.Amount)
+
+	// every lender is stubbed with the same offer
+	offer := Offer{Aamt: terms.Amount, Lvr: terms.LastValid, Lsa: lsa[:]}
+	r := &LsigRegistry{
+		Jina: 7,
+		Dir:  t.TempDir(),
+		Offer: func(lender types.Address) (Offer, error) {
+			o := offer
+			o.Lender = lender
+			return o, nil
+		},
+		Program: func(tmpl LenderTemplate) ([]byte, error) {
+			for amount := range lenderPrograms {
+				terms := testTerms
+				terms.Amount = amount
+				if tmpl == terms.Template(acct.Address) {
+					return lenderProgram(t, amount), nil
+				}
+			}
+			return nil, fmt.Errorf("no lender program for %+v", tmpl)
+		},
+		Round: func() (uint64, error) { return 500, nil },
+	}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := FetchLsig(ctx, srv.URL, acct.Address); err == nil {
+		t.Errorf("fetched an lsig before upload")
+	}
+	if err := UploadLsig(ctx, srv.URL, acct.Address, l.Lsig); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FetchLsig(ctx, srv.URL, acct.Address)
+	if err != nil || !crypto.VerifyLogicSig(got.Lsig, acct.Address) {
+		t.Fatalf("fetched lsig does not verify, %v", err)
+	}
+	if list := r.List(); len(list) != 1 || list[0].Terms != terms {
+		t.Errorf("listed %+v", list)
+	}
+
+	// kept lsigs are loaded again
+	reloaded := &LsigRegistry{Dir: r.Dir}
+	if err := reloaded.load(); err != nil || len(reloaded.lsigs) != 1 {
+		t.Errorf("loaded %d lsigs, %v", len(reloaded.lsigs), err)
+	}
+
+	// another lender cannot upload the lsig as theirs
+	other := crypto.GenerateAccount()
+	if err = UploadLsig(ctx, srv.URL, other.Address, l.Lsig); err == nil {
+		t.Errorf("uploaded the lsig of another lender")
+	}
+
+	// the args are not signed, an lsig whose args alone claim other terms is rejected
+	short := terms
+	short.Amount--
+	forged := l.Lsig
+	forged.Lsig.Args = short.Args()
+	if !crypto.VerifyLogicSig(forged.Lsig, acct.Address) {
+		t.Fatalf("forged args changed the signature")
+	}
+	if err = UploadLsig(ctx, srv.URL, acct.Address, forged); err == nil {
+		t.Errorf("uploaded an lsig with forged args")
+	}
+
+	// lsig for less than the offer is rejected
+	shortLsa := short.OfferID(acct.Address)
+	offer.Lsa = shortLsa[:]
+	if err = UploadLsig(ctx, srv.URL, acct.Address, testLenderLsig(t, short.Amount).Lsig); err == nil {
+		t.Errorf("uploaded an lsig lending less than the offer")
+	}
+
+	// an updated offer drops the lsig
+	offer.Lsa = make([]byte, 32)
+	if _, err = r.Lookup(acct.Address); err != ErrNoLsig {
+		t.Errorf("lookup after offer update: %v", err)
+	}
+	if len(r.List()) != 0 {
+		t.Errorf("lsig of the updated offer still listed")
+	}
+}
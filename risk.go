@@ -0,0 +1,109 @@
+package jina
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+// RiskParams are the manager's risk parameters read by jina and liquidator
+type RiskParams struct {
+	Threshold uint64 // liquidation threshold, percent of collateral value
+	FeeRate   uint64 // fee of offers made without one, in basis points
+	Payment   uint64 // least liquidation payment, percent of lamt
+	Reference uint64 // partial liquidation unit price, percent of oracle price
+	MaxAge    uint64 // rounds an attested price is accepted after the round it was observed
+}
+
+// DefaultRiskParams are set when the manager is created
+var DefaultRiskParams = RiskParams{Threshold: 90, FeeRate: DefaultFeeRate, Payment: 105, Reference: 95, MaxAge: 100}
+
+// Validate mirrors the manager checks of set_risk
+func (r RiskParams) Validate() error {
+	if r.Threshold == 0 {
+		return fmt.Errorf("zero liquidation threshold")
+	}
+	if r.Reference > 100 || r.Reference <= r.Threshold {
+		return fmt.Errorf("liquidation reference %d%% must be above the %d%% threshold and at most 100%%", r.Reference, r.Threshold)
+	}
+	if r.FeeRate >= 10000 {
+		return fmt.Errorf("fee rate %d is not below 10000 basis points", r.FeeRate)
+	}
+	if r.Payment < 100 {
+		return fmt.Errorf("liquidation payment %d%% is less than the loan", r.Payment)
+	}
+	if r.MaxAge == 0 {
+		return fmt.Errorf("zero max age of attested prices")
+	}
+	return nil
+}
+
+// Unhealthy mirrors the liquidator check: loan above the threshold of collateral value
+func (r RiskParams) Unhealthy(p Position, price uint64) bool {
+	return p.Lamt > mulDiv(p.Value(price), r.Threshold, 100)
+}
+
+// LiquidationPayment is the least usdc or jusd the liquidator accepts
+func (r RiskParams) LiquidationPayment(p Position) uint64 {
+	return p.Lamt * r.Payment / 100
+}
+
+// UnitPrice is the price a partial liquidation pays per collateral unit
+func (r RiskParams) UnitPrice(price uint64) uint64 {
+	return price * r.Reference / 100
+}
+
+// Liquidatable lists the positions that are healthy under the current parameters
+// but could be liquidated under r; positions should have their interest accrued
+func (r RiskParams) Liquidatable(current RiskParams, positions []Position, price func(assetID uint64) (uint64, error)) (affected []Position, err error) {
+	for _, p := range positions {
+		var v uint64
+		v, err = price(p.AssetID)
+		if err != nil {
+			return
+		}
+		if !current.Unhealthy(p, v) && r.Unhealthy(p, v) {
+			affected = append(affected, p)
+		}
+	}
+	return
+}
+
+// ReadRiskParams reads the risk parameters from the manager app
+func ReadRiskParams(algodClient *algod.Client, mng uint64) (r RiskParams, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	r = RiskParams{
+		Threshold: state["lt"].Uint,
+		FeeRate:   state["fee"].Uint,
+		Payment:   state["lpay"].Uint,
+		Reference: state["lref"].Uint,
+		MaxAge:    state["page"].Uint,
+	}
+	return
+}
+
+// Make application call to manager mng, the one validated against, setting the risk parameters, for the manager creator only.
+// The open positions the change makes liquidatable are logged as a warning and returned.
+func SetRiskParams(algodClient *algod.Client, acct crypto.Account, mng uint64, r RiskParams, positions []Position, price func(assetID uint64) (uint64, error), contract_json string) (affected []Position, err error) {
+	if err = r.Validate(); err != nil {
+		return
+	}
+	current, err := ReadRiskParams(algodClient, mng)
+	if err != nil {
+		return
+	}
+	affected, err = r.Liquidatable(current, positions, price)
+	if err != nil {
+		return
+	}
+	for _, p := range affected {
+		log.Printf("warning: %s asset %d becomes liquidatable (lamt %d, camt %d)", p.Borrower, p.AssetID, p.Lamt, p.Camt)
+	}
+	err = callAppMethod(algodClient, acct, mng, "set_risk", []interface{}{r.Threshold, r.FeeRate, r.Payment, r.Reference, r.MaxAge}, contract_json)
+	return
+}
@@ -0,0 +1,47 @@
+package jina
+
+import "testing"
+
+func TestRiskParamsValidate(t *testing.T) {
+	if err := DefaultRiskParams.Validate(); err != nil {
+		t.Fatalf("default risk parameters rejected: %v", err)
+	}
+	for _, r := range []RiskParams{
+		{Threshold: 0, FeeRate: 300, Payment: 105, Reference: 95},
+		{Threshold: 95, FeeRate: 300, Payment: 105, Reference: 95},
+		{Threshold: 90, FeeRate: 300, Payment: 105, Reference: 101},
+		{Threshold: 90, FeeRate: 10000, Payment: 105, Reference: 95},
+		{Threshold: 90, FeeRate: 300, Payment: 99, Reference: 95},
+		{Threshold: 90, FeeRate: 300, Payment: 105, Reference: 95},
+	} {
+		if r.Validate() == nil {
+			t.Errorf("invalid risk parameters %+v accepted", r)
+		}
+	}
+}
+
+func TestLiquidatable(t *testing.T) {
+	positions := []Position{
+		{AssetID: 2, Camt: 20, Lamt: 1000}, // 83% of value at 60
+		{AssetID: 2, Camt: 20, Lamt: 1150}, // 96%, already unhealthy
+		{AssetID: 3, Camt: 20, Lamt: 1000}, // 50% of value at 100
+	}
+	price := func(assetID uint64) (uint64, error) {
+		if assetID == 2 {
+			return 60, nil
+		}
+		return 100, nil
+	}
+	lower := DefaultRiskParams
+	lower.Threshold = 80
+	affected, err := lower.Liquidatable(DefaultRiskParams, positions, price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(affected) != 1 || affected[0] != positions[0] {
+		t.Errorf("affected = %+v, want the first position", affected)
+	}
+	if l, ok := lower.PlanLiquidation(positions[0], 60, 0); !ok || l.Payment != 1050 {
+		t.Errorf("lower threshold liquidation %+v %v", l, ok)
+	}
+}
@@ -2,9 +2,11 @@ package jina
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/algorand/go-algorand-sdk/client/kmd"
 	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/mnemonic"
 )
 
 const (
@@ -63,3 +65,23 @@ func GetAccounts() ([]crypto.Account, error) {
 
 	return accts, nil
 }
+
+// LoadAccount recovers the account from the mnemonic in env var mnEnv,
+// falling back to sandbox account at index when it is unset
+func LoadAccount(mnEnv string, index int) (crypto.Account, error) {
+	if mn := os.Getenv(mnEnv); mn != "" {
+		sk, err := mnemonic.ToPrivateKey(mn)
+		if err != nil {
+			return crypto.Account{}, fmt.Errorf("Failed to recover %s: %+v", mnEnv, err)
+		}
+		return crypto.AccountFromPrivateKey(sk)
+	}
+	accts, err := GetAccounts()
+	if err != nil {
+		return crypto.Account{}, err
+	}
+	if index < 0 || index >= len(accts) {
+		return crypto.Account{}, fmt.Errorf("No sandbox account %d", index)
+	}
+	return accts[index], nil
+}
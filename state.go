@@ -0,0 +1,62 @@
+package jina
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+)
+
+// fetch global state of an app keyed by raw (decoded) key
+func globalState(algodClient *algod.Client, appID uint64) (state map[string]models.TealValue, err error) {
+	app, err := algodClient.GetApplicationByID(appID).Do(context.Background())
+	if err != nil {
+		return
+	}
+	state = decodeState(app.Params.GlobalState)
+	return
+}
+
+// fetch local state of an account in an app keyed by raw (decoded) key
+func localState(algodClient *algod.Client, addr string, appID uint64) (state map[string]models.TealValue, err error) {
+	info, err := algodClient.AccountApplicationInformation(addr, appID).Do(context.Background())
+	if err != nil {
+		return
+	}
+	state = decodeState(info.AppLocalState.KeyValue)
+	return
+}
+
+// notFound tells a 404 from algod, e.g. an account not opted in, from a failed request
+func notFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "HTTP 404")
+}
+
+func decodeState(kvs []models.TealKeyValue) map[string]models.TealValue {
+	state := make(map[string]models.TealValue, len(kvs))
+	for _, kv := range kvs {
+		k, err := base64.StdEncoding.DecodeString(kv.Key)
+		if err != nil {
+			continue
+		}
+		state[string(k)] = kv.Value
+	}
+	return state
+}
+
+// decode the bytes value of a state entry
+func stateBytes(v models.TealValue) []byte {
+	b, _ := base64.StdEncoding.DecodeString(v.Bytes)
+	return b
+}
+
+// split a packed uint64 array, as stored in xids, camt and lamt
+func uint64s(b []byte) (vals []uint64) {
+	for i := 0; i+8 <= len(b); i += 8 {
+		vals = append(vals, binary.BigEndian.Uint64(b[i:i+8]))
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package jina
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// TemplateValue is a value substituted for a TMPL_ placeholder, an int, bytes or an address
+type TemplateValue interface {
+	teal() string
+}
+
+// TmplInt is substituted as an int literal, `int TMPL_X`
+type TmplInt uint64
+
+// TmplBytes is substituted as a hex byte literal, `byte TMPL_X`
+type TmplBytes []byte
+
+// TmplAddr is substituted as an address literal, `addr TMPL_X`
+type TmplAddr types.Address
+
+func (v TmplInt) teal() string   { return strconv.FormatUint(uint64(v), 10) }
+func (v TmplBytes) teal() string { return "0x" + hex.EncodeToString(v) }
+func (v TmplAddr) teal() string  { return types.Address(v).String() }
+
+// TemplateParams are the values of a program's TMPL_ placeholders, keyed by name without the TMPL_ prefix
+type TemplateParams map[string]TemplateValue
+
+var tmplName = regexp.MustCompile(`TMPL_[A-Za-z0-9_]+`)
+
+// Substitute replaces the TMPL_ placeholders in the code of a TEAL file, leaving comments as they are.
+// Placeholders without a value and values without a placeholder are errors
+func (p TemplateParams) Substitute(tealFile []byte) ([]byte, error) {
+	used := make(map[string]bool)
+	var missing []string
+	lines := bytes.Split(tealFile, []byte("\n"))
+	for i, line := range lines {
+		code, comment := splitComment(line)
+		code = tmplName.ReplaceAllFunc(code, func(name []byte) []byte {
+			v, ok := p[string(name[len("TMPL_"):])]
+			if !ok {
+				missing = append(missing, string(name))
+				return name
+			}
+			used[string(name[len("TMPL_"):])] = true
+			return []byte(v.teal())
+		})
+		lines[i] = append(code, comment...)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no value for %s", missing[0])
+	}
+	var unused []string
+	for name := range p {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, fmt.Errorf("no placeholder TMPL_%s", unused[0])
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
+
+// splitComment splits a TEAL line at a // outside of string literals
+func splitComment(line []byte) (code, comment []byte) {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && quoted:
+			i++
+		case line[i] == '"':
+			quoted = !quoted
+		case !quoted && line[i] == '/' && i+1 < len(line) && line[i+1] == '/':
+			return line[:i:i], line[i:]
+		}
+	}
+	return line, nil
+}
+
+// ReadTemplate reads a TEAL file and substitutes its TMPL_ placeholders
+func ReadTemplate(osTealFile string, params TemplateParams) ([]byte, error) {
+	tealFile, err := ioutil.ReadFile(osTealFile)
+	if err != nil {
+		return nil, err
+	}
+	tealFile, err = params.Substitute(tealFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", osTealFile, err)
+	}
+	return tealFile, nil
+}
+
+// LenderTemplate are the values baked into logicSigDelegated.teal
+type LenderTemplate struct {
+	Offer     [32]byte // offer id, the note of every borrow
+	USDCa     uint64   // stablecoin lent
+	Amount    uint64   // most a borrow can withdraw
+	LastValid uint64   // round the delegation expires
+	Jina      uint64   // jina app called in the borrow group
+}
+
+func (t LenderTemplate) Params() TemplateParams {
+	return TemplateParams{"LSA": TmplBytes(t.Offer[:]), "USDC": TmplInt(t.USDCa), "AMOUNT": TmplInt(t.Amount), "LASTVALID": TmplInt(t.LastValid), "JINA": TmplInt(t.Jina)}
+}
+
+// DispenserTemplate are the values baked into dispense.teal
+type DispenserTemplate struct {
+	Asset uint64 // asset dispensed
+	Cap   uint64 // most dispensed by one transfer
+}
+
+func (t DispenserTemplate) Params() TemplateParams {
+	return TemplateParams{"ASSET": TmplInt(t.Asset), "CAP": TmplInt(t.Cap)}
+}
@@ -0,0 +1,70 @@
+package jina
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/crypto"
+)
+
+func TestSubstitute(t *testing.T) {
+	addr := crypto.GenerateAccount().Address
+	teal := []byte(`#pragma version 6
+// TMPL_CAP is the cap, TMPL_UNSET is only mentioned
+int TMPL_CAP // at most TMPL_CAP
+int TMPL_CAPS
+byte "// TMPL_NAME"
+byte TMPL_NAME
+addr TMPL_OWNER`)
+	p := TemplateParams{"CAP": TmplInt(4), "CAPS": TmplInt(5), "NAME": TmplBytes("jina"), "OWNER": TmplAddr(addr)}
+	got, err := p.Substitute(teal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `#pragma version 6
+// TMPL_CAP is the cap, TMPL_UNSET is only mentioned
+int 4 // at most TMPL_CAP
+int 5
+byte "// 0x6a696e61"
+byte 0x6a696e61
+addr ` + addr.String()
+	if string(got) != want {
+		t.Errorf("substituted\n%s\nwant\n%s", got, want)
+	}
+
+	delete(p, "OWNER")
+	if _, err = p.Substitute(teal); err == nil || !strings.Contains(err.Error(), "TMPL_OWNER") {
+		t.Errorf("missing value: %v", err)
+	}
+	p["OWNER"] = TmplAddr(addr)
+	p["EXTRA"] = TmplInt(1)
+	if _, err = p.Substitute(teal); err == nil || !strings.Contains(err.Error(), "TMPL_EXTRA") {
+		t.Errorf("unused value: %v", err)
+	}
+}
+
+func TestProgramTemplates(t *testing.T) {
+	terms, lender := testTerms, testLender().Address
+	teal, err := ReadTemplate("./teal/logicSigDelegated.teal", terms.Template(lender).Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsa := terms.OfferID(lender)
+	if !bytes.Contains(teal, []byte("int 10\n")) || !bytes.Contains(teal, []byte("int 7 ")) {
+		t.Errorf("usdc or jina not baked in")
+	}
+	if !bytes.Contains(teal, []byte("int 50000000\n")) || !bytes.Contains(teal, []byte("int 1000\n")) {
+		t.Errorf("amount or expiry not baked in")
+	}
+	if tmpl, _ := ioutil.ReadFile("./teal/logicSigDelegated.teal"); bytes.Equal(tmpl, teal) || !bytes.Contains(teal, []byte(TmplBytes(lsa[:]).teal())) {
+		t.Errorf("offer id not baked in")
+	}
+	if _, err = ReadTemplate("./teal/dispense.teal", DispenserTemplate{Asset: 3, Cap: 4}.Params()); err != nil {
+		t.Error(err)
+	}
+	if _, err = ReadTemplate("./teal/dispense.teal", nil); err == nil {
+		t.Errorf("dispenser compiled without its asset")
+	}
+}
@@ -0,0 +1,315 @@
+package jina
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/bits"
+	"os"
+	"sort"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/algod"
+	"github.com/algorand/go-algorand-sdk/client/v2/common"
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/client/v2/indexer"
+	"github.com/algorand/go-algorand-sdk/crypto"
+	"github.com/algorand/go-algorand-sdk/future"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+// Treasury is the protocol fee account of a market: JUSD jina sent to the manager and paid out
+type Treasury struct {
+	Market
+	Received uint64 // "pfee" of jina
+	Paid     uint64 // "paid"||usdc of the manager
+}
+
+// Balance is the JUSD left to distribute
+func (t Treasury) Balance() uint64 {
+	return t.Received - t.Paid
+}
+
+// Holding is an account's JNA balance
+type Holding struct {
+	Address types.Address
+	Amount  uint64
+}
+
+// Payout is the JUSD an account receives in a distribution
+type Payout struct {
+	Address types.Address
+	Amount  uint64
+}
+
+// ProtocolFeeShare reads the share of lender fees sent to the treasury, in basis points
+func ProtocolFeeShare(algodClient *algod.Client, mng uint64) (share uint64, err error) {
+	state, err := globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	return state["pfs"].Uint, nil
+}
+
+// ReadTreasury reads the protocol fees received and paid out in a market
+func ReadTreasury(algodClient *algod.Client, mng uint64, m Market) (t Treasury, err error) {
+	t.Market = m
+	state, err := globalState(algodClient, m.Jina)
+	if err != nil {
+		return
+	}
+	t.Received = state["pfee"].Uint
+	state, err = globalState(algodClient, mng)
+	if err != nil {
+		return
+	}
+	t.Paid = state[marketKey("paid", m.Stablecoin)].Uint
+	return
+}
+
+// Distribution splits amount, the treasury balance, pro rata over the JNA holders less what is owed from earlier rounds.
+// Holders without a JUSD holding are skipped and their share is added to what they are owed,
+// which is paid with their share of a later round once they opt in. It returns what is owed after the payouts.
+func Distribution(holders []Holding, amount uint64, owed map[types.Address]uint64, optedIn func(addr types.Address) (bool, error)) (payouts []Payout, skipped []types.Address, owes map[types.Address]uint64, err error) {
+	owes = make(map[types.Address]uint64, len(owed))
+	for addr, amt := range owed {
+		owes[addr] = amt
+		if amt > amount {
+			return nil, nil, nil, fmt.Errorf("treasury balance %d short of what is owed", amount)
+		}
+		amount -= amt
+	}
+	total := uint64(0)
+	for _, h := range holders {
+		total += h.Amount
+	}
+	pay := func(addr types.Address, share uint64) error {
+		ok, err := optedIn(addr)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			skipped = append(skipped, addr)
+			owes[addr] += share
+			return nil
+		}
+		if share+owes[addr] > 0 {
+			payouts = append(payouts, Payout{Address: addr, Amount: share + owes[addr]})
+		}
+		delete(owes, addr)
+		return nil
+	}
+	held := make(map[types.Address]bool, len(holders))
+	for _, h := range holders {
+		if h.Amount == 0 {
+			continue
+		}
+		held[h.Address] = true
+		// floor of amount*h.Amount/total, shares never exceed amount
+		hi, lo := bits.Mul64(amount, h.Amount)
+		share, _ := bits.Div64(hi, lo, total)
+		if err = pay(h.Address, share); err != nil {
+			return
+		}
+	}
+	// former holders are still paid what they are owed
+	for addr := range owed {
+		if held[addr] {
+			continue
+		}
+		if err = pay(addr, 0); err != nil {
+			return
+		}
+	}
+	sort.Slice(payouts, func(i, j int) bool { return payouts[i].Amount > payouts[j].Amount })
+	return
+}
+
+// ReadOwed reads the JUSD owed to skipped holders from a JSON file, nothing when there is no file
+func ReadOwed(file string) (owed map[types.Address]uint64, err error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	var byAddr map[string]uint64
+	if err = json.Unmarshal(b, &byAddr); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	owed = make(map[types.Address]uint64, len(byAddr))
+	for a, amt := range byAddr {
+		addr, err := types.DecodeAddress(a)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		owed[addr] = amt
+	}
+	return
+}
+
+// WriteOwed stores the JUSD owed to skipped holders as read by ReadOwed
+func WriteOwed(file string, owed map[types.Address]uint64) error {
+	byAddr := make(map[string]uint64, len(owed))
+	for addr, amt := range owed {
+		byAddr[addr.String()] = amt
+	}
+	b, err := json.MarshalIndent(byAddr, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0644)
+}
+
+// InitIndexerClient makes an indexer client like InitAlgodClient
+func InitIndexerClient(indexerAddress, indexerToken, node string) (*indexer.Client, error) {
+	authHeader := "X-API-Key"
+	if node == "local" {
+		authHeader = "X-Indexer-API-Token"
+	}
+	commonClient, err := common.MakeClient(indexerAddress, authHeader, indexerToken)
+	if err != nil {
+		log.Fatalf("Failed to make common client: %+v", err)
+	}
+	return (*indexer.Client)(commonClient), nil
+}
+
+// JNAHolders lists the JNA balances from the indexer, without the manager's own unissued JNA.
+// JNA locked in votes is held by the manager and counted for its voter.
+func JNAHolders(indexerClient *indexer.Client, mng, jna uint64) (holders []Holding, err error) {
+	treasury := crypto.GetApplicationAddress(mng)
+	locked, err := lockedJNA(indexerClient, mng)
+	if err != nil {
+		return
+	}
+	next := ""
+	for {
+		res, err := indexerClient.LookupAssetBalances(jna).CurrencyGreaterThan(0).NextToken(next).Do(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range res.Balances {
+			addr, err := types.DecodeAddress(b.Address)
+			if err != nil {
+				return nil, err
+			}
+			if addr == treasury || b.Deleted {
+				continue
+			}
+			holders = append(holders, Holding{Address: addr, Amount: b.Amount + locked[addr]})
+			delete(locked, addr)
+		}
+		if res.NextToken == "" || len(res.Balances) == 0 {
+			break
+		}
+		next = res.NextToken
+	}
+	// voters that locked all their JNA
+	for addr, amt := range locked {
+		holders = append(holders, Holding{Address: addr, Amount: amt})
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i].Address.String() < holders[j].Address.String() })
+	return holders, nil
+}
+
+// lockedJNA reads the JNA each voter has locked in the manager, the weight of its "gv" vote
+func lockedJNA(indexerClient *indexer.Client, mng uint64) (locked map[types.Address]uint64, err error) {
+	locked = make(map[types.Address]uint64)
+	next := ""
+	for {
+		res, err := indexerClient.SearchAccounts().ApplicationId(mng).NextToken(next).Do(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, acct := range res.Accounts {
+			addr, err := types.DecodeAddress(acct.Address)
+			if err != nil {
+				return nil, err
+			}
+			for _, ls := range acct.AppsLocalState {
+				if ls.Id != mng {
+					continue
+				}
+				if weight := voteWeight(decodeState(ls.KeyValue)); weight > 0 {
+					locked[addr] = weight
+				}
+			}
+		}
+		if res.NextToken == "" || len(res.Accounts) == 0 {
+			return locked, nil
+		}
+		next = res.NextToken
+	}
+}
+
+// voteWeight is the JNA locked in a manager local state vote, proposal ID||weight
+func voteWeight(state map[string]models.TealValue) uint64 {
+	vote := stateBytes(state["gv"])
+	if len(vote) != 16 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(vote[8:])
+}
+
+// HoldsAsset reports whether an account has opted in to an asset
+func HoldsAsset(algodClient *algod.Client, addr types.Address, asset uint64) (bool, error) {
+	_, err := algodClient.AccountAssetInformation(addr.String(), asset).Do(context.Background())
+	if notFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Make manager application call to set the share of lender fees sent to the treasury, in basis points
+func SetProtocolFeeShare(algodClient *algod.Client, acct crypto.Account, share uint64, contract_json string) (err error) {
+	if share > 10000 {
+		return fmt.Errorf("protocol fee share %d above 10000 basis points", share)
+	}
+	return callMethod(algodClient, acct, "set_protocol_fee", []interface{}{share}, contract_json)
+}
+
+// Make manager application calls paying out a distribution from a market's treasury, 16 per group
+func Distribute(algodClient *algod.Client, acct crypto.Account, m Market, payouts []Payout, contract_json string) (err error) {
+	contract, err := getContract(contract_json)
+	if err != nil {
+		return
+	}
+
+	txParams, err := algodClient.SuggestedParams().Do(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to get suggeted params: %+v", err)
+	}
+	// pay for the JUSD transfer
+	txParams.FlatFee = true
+	txParams.Fee = types.MicroAlgos(2 * txParams.MinFee)
+
+	signer := future.BasicAccountTransactionSigner{Account: acct}
+
+	mcp := future.AddMethodCallParams{
+		AppID:           contract.Networks["default"].AppID,
+		Sender:          acct.Address,
+		SuggestedParams: txParams,
+		OnComplete:      types.NoOpOC,
+		Signer:          signer,
+	}
+
+	for len(payouts) > 0 {
+		n := len(payouts)
+		if n > future.MaxAtomicGroupSize {
+			n = future.MaxAtomicGroupSize
+		}
+		var atc future.AtomicTransactionComposer
+		for _, p := range payouts[:n] {
+			err = atc.AddMethodCall(combine(mcp, getMethod(contract, "distribute"), []interface{}{m.Stablecoin, m.Jina, m.IOU, p.Address, p.Amount}))
+			if err != nil {
+				log.Fatalf("Failed to AddMethodCall: %+v", err)
+			}
+		}
+		debugAppCall(algodClient, atc, "./dryrun/distribute.msgp", "./dryrun/response/distribute.json")
+		payouts = payouts[n:]
+	}
+	return
+}
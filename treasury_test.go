@@ -0,0 +1,85 @@
+package jina
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/algorand/go-algorand-sdk/client/v2/common/models"
+	"github.com/algorand/go-algorand-sdk/types"
+)
+
+func TestDistribution(t *testing.T) {
+	a, b, c := types.Address{1}, types.Address{2}, types.Address{3}
+	holders := []Holding{{a, 500}, {b, 300}, {c, 200}, {types.Address{4}, 0}}
+	optedIn := func(addr types.Address) (bool, error) { return addr != c, nil }
+	payouts, skipped, owed, err := Distribution(holders, 1001, nil, optedIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0] != c {
+		t.Errorf("skipped = %v, want only c", skipped)
+	}
+	// c is owed its share
+	want := []Payout{{a, 500}, {b, 300}}
+	if len(payouts) != len(want) {
+		t.Fatalf("payouts = %+v, want %+v", payouts, want)
+	}
+	for i := range want {
+		if payouts[i] != want[i] {
+			t.Errorf("payouts[%d] = %+v, want %+v", i, payouts[i], want[i])
+		}
+	}
+	if len(owed) != 1 || owed[c] != 200 {
+		t.Errorf("owed = %v, want 200 to c", owed)
+	}
+	if tr := (Treasury{Received: 1001, Paid: 800}); tr.Balance() != 201 {
+		t.Errorf("balance = %d", tr.Balance())
+	}
+
+	// the next round splits only what is not owed, and pays c its share and what it is owed once opted in
+	payouts, _, owed, err = Distribution(holders, 1201, owed, func(types.Address) (bool, error) { return true, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Payout{{a, 500}, {c, 400}, {b, 300}}
+	if len(payouts) != len(want) || len(owed) != 0 {
+		t.Fatalf("payouts = %+v owed %v, want %+v", payouts, owed, want)
+	}
+	for i := range want {
+		if payouts[i] != want[i] {
+			t.Errorf("payouts[%d] = %+v, want %+v", i, payouts[i], want[i])
+		}
+	}
+
+	if _, _, _, err = Distribution(holders, 100, map[types.Address]uint64{c: 200}, optedIn); err == nil {
+		t.Errorf("distributed a balance short of what is owed")
+	}
+	if _, _, _, err = Distribution(holders, 1001, nil, func(types.Address) (bool, error) { return false, errors.New("HTTP 500") }); err == nil {
+		t.Errorf("failed opt in lookup skipped the holder")
+	}
+}
+
+func TestOwedFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "owed.json")
+	if owed, err := ReadOwed(file); err != nil || len(owed) != 0 {
+		t.Fatalf("missing file read as %v, %v", owed, err)
+	}
+	c := types.Address{3}
+	if err := WriteOwed(file, map[types.Address]uint64{c: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if owed, err := ReadOwed(file); err != nil || len(owed) != 1 || owed[c] != 200 {
+		t.Errorf("read back %v, %v", owed, err)
+	}
+}
+
+func TestVoteWeight(t *testing.T) {
+	state := map[string]models.TealValue{"gv": packed(7, 300)}
+	if got := voteWeight(state); got != 300 {
+		t.Errorf("vote weight %d, want 300", got)
+	}
+	if got := voteWeight(nil); got != 0 {
+		t.Errorf("weight %d without a vote", got)
+	}
+}